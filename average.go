@@ -5,12 +5,23 @@ import (
 	"image/color"
 )
 
+// RowAverageGray16 concurrently averages runs of radius values along the rows
+// of a Channel, returning an *image.Gray16 whose value at x is the average,
+// rounded down, of the values from x to x+radius-1. Its bounds extend
+// radius-1 to the left of the Channel's, so that every run overlapping the
+// Channel has a result, and only the values within the Channel's bounds are
+// averaged, even when the runs are wider than the Channel.
 func RowAverageGray16(radius int, img Channel) *image.Gray16 {
+	return rowAverageGray16(DefaultScheduler, radius, img)
+}
+
+// rowAverageGray16 implements RowAverageGray16 using the given Scheduler.
+func rowAverageGray16(s *Scheduler, radius int, img Channel) *image.Gray16 {
 	bounds := img.Bounds()
 	resultBounds := image.Rect(bounds.Min.X-radius+1, bounds.Min.Y, bounds.Max.X, bounds.Max.Y)
 	resultImg := image.NewGray16(resultBounds)
 
-	QuickRowsRP(
+	s.RowsRP(
 		RowsRP(1, func(rect image.Rectangle) {
 			y := rect.Min.Y
 			n := 0
@@ -19,8 +30,12 @@ func RowAverageGray16(radius int, img Channel) *image.Gray16 {
 			// Heads.
 			x := resultBounds.Min.X
 			for ; x <= bounds.Min.X; x++ {
-				n += int(img.Gray16At(x+radius-1, y).Y)
-				d++
+
+				// Windows wider than the image reach beyond the bounds.
+				if x+radius-1 < bounds.Max.X {
+					n += int(img.Gray16At(x+radius-1, y).Y)
+					d++
+				}
 
 				resultImg.Set(x, y, color.Gray16{
 					Y: uint16(n / d),
@@ -52,12 +67,21 @@ func RowAverageGray16(radius int, img Channel) *image.Gray16 {
 	return resultImg
 }
 
+// ColumnAverageGray16 is the column counterpart to RowAverageGray16, with
+// bounds extending radius-1 above the Channel's and averaging only the values
+// within them, even when the runs are taller than the Channel.
 func ColumnAverageGray16(radius int, img Channel) *image.Gray16 {
+	return columnAverageGray16(DefaultScheduler, radius, img)
+}
+
+// columnAverageGray16 implements ColumnAverageGray16 using the given
+// Scheduler.
+func columnAverageGray16(s *Scheduler, radius int, img Channel) *image.Gray16 {
 	bounds := img.Bounds()
 	resultBounds := image.Rect(bounds.Min.X, bounds.Min.Y-radius+1, bounds.Max.X, bounds.Max.Y)
 	resultImg := image.NewGray16(resultBounds)
 
-	QuickColumnsRP(
+	s.ColumnsRP(
 		ColumnsRP(1, func(rect image.Rectangle) {
 			x := rect.Min.X
 			n := 0
@@ -66,8 +90,12 @@ func ColumnAverageGray16(radius int, img Channel) *image.Gray16 {
 			// Heads.
 			y := resultBounds.Min.Y
 			for ; y <= bounds.Min.Y; y++ {
-				n += int(img.Gray16At(x, y+radius-1).Y)
-				d++
+
+				// Windows taller than the image reach beyond the bounds.
+				if y+radius-1 < bounds.Max.Y {
+					n += int(img.Gray16At(x, y+radius-1).Y)
+					d++
+				}
 
 				resultImg.Set(x, y, color.Gray16{
 					Y: uint16(n / d),
@@ -99,6 +127,192 @@ func ColumnAverageGray16(radius int, img Channel) *image.Gray16 {
 	return resultImg
 }
 
+// CentredRowAverageGray16 concurrently averages each value of a Channel with
+// those within the given radius either side of it on the same row, treating
+// the coordinates beyond the bounds as the given Border does, and returns an
+// *image.Gray16 with the same bounds.
+func CentredRowAverageGray16(radius int, border Border, img Channel) *image.Gray16 {
+	return windowAverageGray16(DefaultScheduler, -radius, radius, border, false, img)
+}
+
+// CentredColumnAverageGray16 is the column counterpart to
+// CentredRowAverageGray16.
+func CentredColumnAverageGray16(radius int, border Border, img Channel) *image.Gray16 {
+	return windowAverageGray16(DefaultScheduler, -radius, radius, border, true, img)
+}
+
+// windowAverageGray16 concurrently sets each value of an *image.Gray16 with
+// the bounds of a Channel to the rounded average of the values from lo to hi
+// along the row, or the column if vertical is true, relative to it. The
+// coordinates beyond the bounds are treated as the given Border does.
+func windowAverageGray16(s *Scheduler, lo, hi int, border Border, vertical bool, img Channel) *image.Gray16 {
+	bounds := img.Bounds()
+	resultImg := image.NewGray16(bounds)
+
+	// Slide the window along a line, adding the value entering it and
+	// subtracting the value leaving it.
+	line := func(min, max int, get func(i int) uint16, set func(i int, v uint16)) {
+		var n, d int
+		for k := lo; k <= hi; k++ {
+			if v, ok := border.sample(min+k, min, max, get); ok {
+				n += int(v)
+				d++
+			}
+		}
+
+		for i := min; i < max; i++ {
+			if d > 0 {
+				set(i, uint16((n+d/2)/d))
+			}
+
+			if v, ok := border.sample(i+lo, min, max, get); ok {
+				n -= int(v)
+				d--
+			}
+			if v, ok := border.sample(i+1+hi, min, max, get); ok {
+				n += int(v)
+				d++
+			}
+		}
+	}
+
+	if vertical {
+		s.ColumnsRP(
+			ColumnsRP(1, func(rect image.Rectangle) {
+				x := rect.Min.X
+				line(bounds.Min.Y, bounds.Max.Y,
+					func(y int) uint16 {
+						return img.Gray16At(x, y).Y
+					},
+					func(y int, v uint16) {
+						resultImg.SetGray16(x, y, color.Gray16{Y: v})
+					},
+				)
+			}),
+		)(bounds)
+	} else {
+		s.RowsRP(
+			RowsRP(1, func(rect image.Rectangle) {
+				y := rect.Min.Y
+				line(bounds.Min.X, bounds.Max.X,
+					func(x int) uint16 {
+						return img.Gray16At(x, y).Y
+					},
+					func(x int, v uint16) {
+						resultImg.SetGray16(x, y, color.Gray16{Y: v})
+					},
+				)
+			}),
+		)(bounds)
+	}
+
+	return resultImg
+}
+
+// RowAverageFloat is the FloatChannel counterpart to RowAverageGray16,
+// averaging without rounding or clamping.
+func RowAverageFloat(radius int, img FloatChannel) *FloatGray {
+	return rowAverageFloat(DefaultScheduler, radius, img)
+}
+
+// rowAverageFloat implements RowAverageFloat using the given Scheduler.
+func rowAverageFloat(s *Scheduler, radius int, img FloatChannel) *FloatGray {
+	bounds := img.Bounds()
+	resultBounds := image.Rect(bounds.Min.X-radius+1, bounds.Min.Y, bounds.Max.X, bounds.Max.Y)
+	resultImg := NewFloatGray(resultBounds)
+
+	s.RowsRP(
+		RowsRP(1, func(rect image.Rectangle) {
+			y := rect.Min.Y
+			n := 0.0
+			d := 0
+
+			// Heads.
+			x := resultBounds.Min.X
+			for ; x <= bounds.Min.X; x++ {
+
+				// Windows wider than the image reach beyond the bounds.
+				if x+radius-1 < bounds.Max.X {
+					n += float64(img.FloatAt(x+radius-1, y))
+					d++
+				}
+
+				resultImg.SetFloat(x, y, float32(n/float64(d)))
+			}
+
+			// Middle.
+			for ; x <= bounds.Max.X-radius; x++ {
+				n += float64(img.FloatAt(x+radius-1, y))
+				n -= float64(img.FloatAt(x-1, y))
+
+				resultImg.SetFloat(x, y, float32(n/float64(d)))
+			}
+
+			// Tails.
+			for ; x < bounds.Max.X; x++ {
+				n -= float64(img.FloatAt(x-1, y))
+				d--
+
+				resultImg.SetFloat(x, y, float32(n/float64(d)))
+			}
+		}),
+	)(bounds)
+
+	return resultImg
+}
+
+// ColumnAverageFloat is the FloatChannel counterpart to ColumnAverageGray16,
+// averaging without rounding or clamping.
+func ColumnAverageFloat(radius int, img FloatChannel) *FloatGray {
+	return columnAverageFloat(DefaultScheduler, radius, img)
+}
+
+// columnAverageFloat implements ColumnAverageFloat using the given Scheduler.
+func columnAverageFloat(s *Scheduler, radius int, img FloatChannel) *FloatGray {
+	bounds := img.Bounds()
+	resultBounds := image.Rect(bounds.Min.X, bounds.Min.Y-radius+1, bounds.Max.X, bounds.Max.Y)
+	resultImg := NewFloatGray(resultBounds)
+
+	s.ColumnsRP(
+		ColumnsRP(1, func(rect image.Rectangle) {
+			x := rect.Min.X
+			n := 0.0
+			d := 0
+
+			// Heads.
+			y := resultBounds.Min.Y
+			for ; y <= bounds.Min.Y; y++ {
+
+				// Windows taller than the image reach beyond the bounds.
+				if y+radius-1 < bounds.Max.Y {
+					n += float64(img.FloatAt(x, y+radius-1))
+					d++
+				}
+
+				resultImg.SetFloat(x, y, float32(n/float64(d)))
+			}
+
+			// Middle.
+			for ; y <= bounds.Max.Y-radius; y++ {
+				n += float64(img.FloatAt(x, y+radius-1))
+				n -= float64(img.FloatAt(x, y-1))
+
+				resultImg.SetFloat(x, y, float32(n/float64(d)))
+			}
+
+			// Tails.
+			for ; y < bounds.Max.Y; y++ {
+				n -= float64(img.FloatAt(x, y-1))
+				d--
+
+				resultImg.SetFloat(x, y, float32(n/float64(d)))
+			}
+		}),
+	)(bounds)
+
+	return resultImg
+}
+
 func AverageGray16(rect image.Rectangle, img Channel) color.Gray16 {
 
 	// Only use the area of the rectangle that overlaps with the image bounds.
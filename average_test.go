@@ -51,3 +51,87 @@ func TestColumnAverageGray16(t *testing.T) {
 		}
 	}
 }
+
+func TestAverageWiderThanImage(t *testing.T) {
+	values := []uint16{100, 200, 600}
+	row := image.NewGray16(image.Rect(4, 0, 7, 1))
+	column := image.NewGray16(image.Rect(0, 4, 1, 7))
+	for i, v := range values {
+		row.SetGray16(4+i, 0, color.Gray16{Y: v})
+		column.SetGray16(0, 4+i, color.Gray16{Y: v})
+	}
+
+	// Each window only averages the values within the bounds, so the integer
+	// and float versions agree.
+	const radius = 5
+	averages := map[string]func(i int) float64{
+		"row": func(i int) float64 {
+			return float64(RowAverageGray16(radius, row).Gray16At(i, 0).Y)
+		},
+		"column": func(i int) float64 {
+			return float64(ColumnAverageGray16(radius, column).Gray16At(0, i).Y)
+		},
+		"float row": func(i int) float64 {
+			return float64(RowAverageFloat(radius, ChannelToFloat(row)).FloatAt(i, 0)) * 0xffff
+		},
+		"float column": func(i int) float64 {
+			return float64(ColumnAverageFloat(radius, ChannelToFloat(column)).FloatAt(0, i)) * 0xffff
+		},
+	}
+	for name, average := range averages {
+		for i := 4 - radius + 1; i < 7; i++ {
+			var n, d float64
+			for j := i; j < i+radius; j++ {
+				if j >= 4 && j < 7 {
+					n += float64(values[j-4])
+					d++
+				}
+			}
+			if v := average(i); v < n/d-1 || v > n/d+1e-3 {
+				t.Errorf("%s average at %d is %v, expected %v", name, i, v, n/d)
+			}
+		}
+	}
+}
+
+func TestCentredAverageGray16(t *testing.T) {
+	const radius = 3
+	values := []uint16{100, 900, 200, 5000, 30, 70, 4000, 10}
+
+	row := image.NewGray16(image.Rect(-2, 4, 6, 5))
+	column := image.NewGray16(image.Rect(4, -2, 5, 6))
+	for i, v := range values {
+		row.SetGray16(i-2, 4, color.Gray16{Y: v})
+		column.SetGray16(4, i-2, color.Gray16{Y: v})
+	}
+
+	for _, border := range []Border{BorderClamp, BorderMirror, BorderWrap, BorderZero, BorderShrink} {
+		rowAverage := CentredRowAverageGray16(radius, border, row)
+		columnAverage := CentredColumnAverageGray16(radius, border, column)
+		if rowAverage.Rect != row.Rect || columnAverage.Rect != column.Rect {
+			t.Errorf("border %d averages have bounds %v and %v", border, rowAverage.Rect, columnAverage.Rect)
+		}
+
+		for i := range values {
+			n, d := 0, 0
+			for k := i - radius; k <= i+radius; k++ {
+				j, ok := border.index(k, 0, len(values))
+				if !ok {
+					continue
+				}
+				if j >= 0 && j < len(values) {
+					n += int(values[j])
+				}
+				d++
+			}
+			expected := uint16((n + d/2) / d)
+
+			if y := rowAverage.Gray16At(i-2, 4).Y; y != expected {
+				t.Errorf("border %d row average is %d at %d, expected %d", border, y, i-2, expected)
+			}
+			if y := columnAverage.Gray16At(4, i-2).Y; y != expected {
+				t.Errorf("border %d column average is %d at %d, expected %d", border, y, i-2, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// BlendMode is a separable or non-separable blend mode, as defined by the W3C
+// Compositing and Blending specification, that mixes the colors of a source
+// and a backdrop before the source is placed over the backdrop.
+type BlendMode int
+
+const (
+	// BlendNormal uses the source color.
+	BlendNormal BlendMode = iota
+
+	// BlendMultiply multiplies the source and backdrop colors.
+	BlendMultiply
+
+	// BlendScreen multiplies the complements of the source and backdrop
+	// colors and complements the result.
+	BlendScreen
+
+	// BlendOverlay multiplies or screens the colors depending on the
+	// backdrop color.
+	BlendOverlay
+
+	// BlendSoftLight darkens or lightens the colors depending on the source
+	// color, like a diffused spotlight.
+	BlendSoftLight
+
+	// BlendHardLight multiplies or screens the colors depending on the
+	// source color, like a harsh spotlight.
+	BlendHardLight
+
+	// BlendColorDodge brightens the backdrop color to reflect the source
+	// color.
+	BlendColorDodge
+
+	// BlendColorBurn darkens the backdrop color to reflect the source color.
+	BlendColorBurn
+
+	// BlendDarken selects the darker of the source and backdrop colors.
+	BlendDarken
+
+	// BlendLighten selects the lighter of the source and backdrop colors.
+	BlendLighten
+
+	// BlendDifference subtracts the darker of the source and backdrop colors
+	// from the lighter.
+	BlendDifference
+
+	// BlendExclusion is BlendDifference with lower contrast.
+	BlendExclusion
+
+	// BlendHue uses the hue of the source color with the saturation and
+	// luminosity of the backdrop color.
+	BlendHue
+
+	// BlendSaturation uses the saturation of the source color with the hue
+	// and luminosity of the backdrop color.
+	BlendSaturation
+
+	// BlendColor uses the hue and saturation of the source color with the
+	// luminosity of the backdrop color.
+	BlendColor
+
+	// BlendLuminosity uses the luminosity of the source color with the hue
+	// and saturation of the backdrop color.
+	BlendLuminosity
+)
+
+// rgb is a non-premultiplied color with components between 0 and 1.
+type rgb [3]float64
+
+// blend returns the mix of a backdrop color cb and a source color cs.
+func (mode BlendMode) blend(cb, cs rgb) rgb {
+	switch mode {
+	case BlendHue:
+		return setLum(setSat(cs, sat(cb)), lum(cb))
+	case BlendSaturation:
+		return setLum(setSat(cb, sat(cs)), lum(cb))
+	case BlendColor:
+		return setLum(cs, lum(cb))
+	case BlendLuminosity:
+		return setLum(cb, lum(cs))
+	}
+
+	var c rgb
+	for i := range c {
+		c[i] = mode.blendComponent(cb[i], cs[i])
+	}
+	return c
+}
+
+// blendComponent returns the mix of a single component of a backdrop color
+// cb and a source color cs for a separable blend mode.
+func (mode BlendMode) blendComponent(cb, cs float64) float64 {
+	switch mode {
+	case BlendMultiply:
+		return cb * cs
+	case BlendScreen:
+		return cb + cs - cb*cs
+	case BlendOverlay:
+		return BlendHardLight.blendComponent(cs, cb)
+	case BlendSoftLight:
+		if cs <= 0.5 {
+			return cb - (1-2*cs)*cb*(1-cb)
+		}
+
+		d := math.Sqrt(cb)
+		if cb <= 0.25 {
+			d = ((16*cb-12)*cb + 4) * cb
+		}
+		return cb + (2*cs-1)*(d-cb)
+	case BlendHardLight:
+		if cs <= 0.5 {
+			return BlendMultiply.blendComponent(cb, 2*cs)
+		}
+		return BlendScreen.blendComponent(cb, 2*cs-1)
+	case BlendColorDodge:
+		switch {
+		case cb == 0:
+			return 0
+		case cs == 1:
+			return 1
+		}
+		return math.Min(1, cb/(1-cs))
+	case BlendColorBurn:
+		switch {
+		case cb == 1:
+			return 1
+		case cs == 0:
+			return 0
+		}
+		return 1 - math.Min(1, (1-cb)/cs)
+	case BlendDarken:
+		return math.Min(cb, cs)
+	case BlendLighten:
+		return math.Max(cb, cs)
+	case BlendDifference:
+		return math.Abs(cb - cs)
+	case BlendExclusion:
+		return cb + cs - 2*cb*cs
+	}
+	return cs
+}
+
+// lum returns the luminosity of a color.
+func lum(c rgb) float64 {
+	return 0.3*c[0] + 0.59*c[1] + 0.11*c[2]
+}
+
+// setLum returns a color with the hue and saturation of c and luminosity l.
+func setLum(c rgb, l float64) rgb {
+	d := l - lum(c)
+	return clipColor(rgb{c[0] + d, c[1] + d, c[2] + d})
+}
+
+// clipColor brings the components of a color back between 0 and 1 while
+// preserving its luminosity.
+func clipColor(c rgb) rgb {
+	l := lum(c)
+	n := math.Min(c[0], math.Min(c[1], c[2]))
+	x := math.Max(c[0], math.Max(c[1], c[2]))
+
+	for i := range c {
+		if n < 0 {
+			c[i] = l + (c[i]-l)*l/(l-n)
+		}
+		if x > 1 {
+			c[i] = l + (c[i]-l)*(1-l)/(x-l)
+		}
+	}
+	return c
+}
+
+// sat returns the saturation of a color.
+func sat(c rgb) float64 {
+	return math.Max(c[0], math.Max(c[1], c[2])) - math.Min(c[0], math.Min(c[1], c[2]))
+}
+
+// setSat returns a color with the hue of c and saturation s.
+func setSat(c rgb, s float64) rgb {
+
+	// Find the indexes of the lowest, middle and highest components.
+	lo, md, hi := 0, 1, 2
+	if c[lo] > c[md] {
+		lo, md = md, lo
+	}
+	if c[md] > c[hi] {
+		md, hi = hi, md
+	}
+	if c[lo] > c[md] {
+		lo, md = md, lo
+	}
+
+	var r rgb
+	if c[hi] > c[lo] {
+		r[md] = (c[md] - c[lo]) * s / (c[hi] - c[lo])
+		r[hi] = s
+	}
+	return r
+}
+
+// Blend concurrently mixes each pixel of a source ImageReader with the pixel
+// at the same coordinates of a destination ImageReadWriter using the given
+// blend mode, and places the result over the destination with the given
+// opacity between 0 and 1, over the intersection of their bounds. Colors are
+// mixed at the precision of color.NRGBA64.
+func Blend(dst ImageReadWriter, src ImageReader, mode BlendMode, opacity float64) {
+	if opacity <= 0 {
+		return
+	}
+	if opacity > 1 {
+		opacity = 1
+	}
+
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				s := color.NRGBA64Model.Convert(src.At(pt.X, pt.Y)).(color.NRGBA64)
+				b := color.NRGBA64Model.Convert(dst.At(pt.X, pt.Y)).(color.NRGBA64)
+
+				as := float64(s.A) / math.MaxUint16 * opacity
+				if as == 0 {
+					return
+				}
+
+				ab := float64(b.A) / math.MaxUint16
+				cs := rgb{
+					float64(s.R) / math.MaxUint16,
+					float64(s.G) / math.MaxUint16,
+					float64(s.B) / math.MaxUint16,
+				}
+				cb := rgb{
+					float64(b.R) / math.MaxUint16,
+					float64(b.G) / math.MaxUint16,
+					float64(b.B) / math.MaxUint16,
+				}
+
+				// Mix the colors where the backdrop is opaque, then place the
+				// result over the backdrop.
+				mixed := mode.blend(cb, cs)
+				ao := as + ab*(1-as)
+
+				var co [3]uint16
+				for i := range co {
+					c := (1-ab)*cs[i] + ab*mixed[i]
+					c = (as*c + ab*cb[i]*(1-as)) / ao
+					co[i] = uint16(math.Max(0, math.Min(1, c))*math.MaxUint16 + 0.5)
+				}
+
+				dst.Set(pt.X, pt.Y, color.NRGBA64{
+					R: co[0],
+					G: co[1],
+					B: co[2],
+					A: uint16(ao*math.MaxUint16 + 0.5),
+				})
+			},
+		),
+	)(dst.Bounds().Intersect(src.Bounds()))
+}
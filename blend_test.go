@@ -0,0 +1,78 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlend(t *testing.T) {
+	cases := []struct {
+		src, dst color.NRGBA64
+		opacity  float64
+	}{
+		{color.NRGBA64{0xc000, 0x4000, 0x2000, 0xffff}, color.NRGBA64{0x3000, 0x8000, 0xe000, 0xffff}, 1},
+		{color.NRGBA64{0xc000, 0x4000, 0x2000, 0x8000}, color.NRGBA64{0x3000, 0x8000, 0xe000, 0xc000}, 0.8},
+	}
+
+	// Golden values computed independently from the formulas of the W3C
+	// Compositing and Blending specification.
+	golden := map[BlendMode][2]color.NRGBA64{
+		BlendNormal:     {{0xc000, 0x4000, 0x2000, 0xffff}, {0x73c4, 0x61e2, 0x85a6, 0xd999}},
+		BlendMultiply:   {{0x2400, 0x2000, 0x1c00, 0xffff}, {0x3cb5, 0x5697, 0x843c, 0xd999}},
+		BlendScreen:     {{0xcc00, 0xa000, 0xe400, 0xffff}, {0x7800, 0x83c4, 0xcad3, 0xd999}},
+		BlendOverlay:    {{0x4800, 0x4001, 0xc801, 0xffff}, {0x4969, 0x61e2, 0xc0f1, 0xd999}},
+		BlendSoftLight:  {{0x4f80, 0x6000, 0xcb01, 0xffff}, {0x4c0f, 0x6d2d, 0xc200, 0xd999}},
+		BlendHardLight:  {{0x9801, 0x4000, 0x3800, 0xffff}, {0x65a6, 0x61e2, 0x8e1e, 0xd999}},
+		BlendColorDodge: {{0xc002, 0xaaab, 0xffff, 0xffff}, {0x73c5, 0x8788, 0xd4b5, 0xd999}},
+		BlendColorBurn:  {{0x0000, 0x0000, 0x0008, 0xffff}, {0x3000, 0x4b4b, 0x7a5d, 0xd999}},
+		BlendDarken:     {{0x3000, 0x4000, 0x2000, 0xffff}, {0x40f1, 0x61e2, 0x85a6, 0xd999}},
+		BlendLighten:    {{0xc000, 0x8000, 0xe000, 0xffff}, {0x73c4, 0x7879, 0xc96a, 0xd999}},
+		BlendDifference: {{0x9000, 0x4000, 0xc000, 0xffff}, {0x62d3, 0x61e2, 0xbe1e, 0xd999}},
+		BlendExclusion:  {{0xa800, 0x8000, 0xc800, 0xffff}, {0x6b4b, 0x7878, 0xc0f1, 0xd999}},
+		BlendHue:        {{0xd8fe, 0x4c31, 0x28fe, 0xffff}, {0x7c96, 0x662f, 0x88d2, 0xd999}},
+		BlendSaturation: {{0x360d, 0x7ec7, 0xd60d, 0xffff}, {0x4313, 0x780a, 0xc5e7, 0xd999}},
+		BlendColor:      {{0xcfae, 0x4fae, 0x2fae, 0xffff}, {0x794d, 0x676b, 0x8b2e, 0xd999}},
+		BlendLuminosity: {{0x2052, 0x7052, 0xd052, 0xffff}, {0x3b68, 0x72f0, 0xc3e1, 0xd999}},
+	}
+
+	for mode, expected := range golden {
+		for i, c := range cases {
+			src := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+			src.SetNRGBA64(0, 0, c.src)
+			dst := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+			dst.SetNRGBA64(0, 0, c.dst)
+
+			Blend(dst, src, mode, c.opacity)
+
+			found := dst.NRGBA64At(0, 0)
+			for _, v := range [][2]uint16{
+				{found.R, expected[i].R},
+				{found.G, expected[i].G},
+				{found.B, expected[i].B},
+				{found.A, expected[i].A},
+			} {
+				if d := int(v[0]) - int(v[1]); d < -1 || d > 1 {
+					t.Errorf("mode %d case %d: expected %v, found %v", mode, i, expected[i], found)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestBlendOpacity(t *testing.T) {
+	rect := image.Rect(0, 0, 10, 10)
+	src := randomNRGBA64(rect)
+	background := ConvertToNRGBA64(randomNRGBA64(rect))
+
+	dst := image.NewNRGBA64(rect)
+	Copy(dst, background)
+	Blend(dst, src, BlendMultiply, 0)
+
+	for i := range dst.Pix {
+		if dst.Pix[i] != background.Pix[i] {
+			t.Fatal("blending with zero opacity changed the destination")
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// BoxBlurFloat concurrently blurs a FloatChannel by averaging each value with
+// those within the given radius horizontally and vertically, returning a
+// *FloatGray with the same bounds. Near the edges, only the values within the
+// bounds are averaged.
+func BoxBlurFloat(radius int, img FloatChannel) *FloatGray {
+	return boxBlurFloat(DefaultScheduler, radius, img)
+}
+
+// boxBlurFloat implements BoxBlurFloat using the given Scheduler.
+func boxBlurFloat(s *Scheduler, radius int, img FloatChannel) *FloatGray {
+	bounds := img.Bounds()
+	if radius < 1 {
+		return floatCopy(s, img)
+	}
+
+	// The averages are of trailing windows, so shift them back by the radius
+	// to centre them.
+	size := 2*radius + 1
+	rows := rowAverageFloat(s, size, img)
+	rows.Rect = rows.Rect.Add(image.Pt(radius, 0))
+	rows = rows.SubImage(bounds).(*FloatGray)
+
+	columns := columnAverageFloat(s, size, rows)
+	columns.Rect = columns.Rect.Add(image.Pt(0, radius))
+	return columns.SubImage(bounds).(*FloatGray)
+}
+
+// floatCopy concurrently copies a FloatChannel to a new *FloatGray.
+func floatCopy(s *Scheduler, img FloatChannel) *FloatGray {
+	dst := NewFloatGray(img.Bounds())
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				dst.SetFloat(pt.X, pt.Y, img.FloatAt(pt.X, pt.Y))
+			},
+		),
+	)(dst.Rect)
+	return dst
+}
+
+// BoxBlurGray16 is BoxBlurFloat for a Channel, rounding the result to an
+// *image.Gray16 only once all passes are complete.
+func BoxBlurGray16(radius int, img Channel) *image.Gray16 {
+	return FloatToGray16(BoxBlurFloat(radius, ChannelToFloat(img)))
+}
+
+// BoxBlur is BoxBlurFloat for an ImageReader, blurring its colors
+// premultiplied by alpha and returning an *image.NRGBA64 with the same
+// bounds.
+func BoxBlur(radius int, img ImageReader) *image.NRGBA64 {
+	return filterImage(img, true, func(c FloatChannel) *FloatGray {
+		return BoxBlurFloat(radius, c)
+	})
+}
+
+// gaussianBoxes returns the radii of n successive box blurs that together
+// approximate a Gaussian blur with the given standard deviation.
+func gaussianBoxes(sigma float64, n int) []int {
+	// Find the odd sizes either side of the ideal size, and how many of the
+	// smaller size to use so that the variances sum to that of the Gaussian.
+	ideal := math.Sqrt(12*sigma*sigma/float64(n) + 1)
+	lower := int(ideal)
+	if lower%2 == 0 {
+		lower--
+	}
+	upper := lower + 2
+
+	l := float64(lower)
+	m := int(math.Round((12*sigma*sigma - float64(n)*l*l - 4*float64(n)*l - 3*float64(n)) / (-4*l - 4)))
+
+	radii := make([]int, n)
+	for i := range radii {
+		if i < m {
+			radii[i] = (lower - 1) / 2
+		} else {
+			radii[i] = (upper - 1) / 2
+		}
+	}
+	return radii
+}
+
+// GaussianBlurFloat concurrently blurs a FloatChannel with an approximation
+// of a Gaussian with the given standard deviation made from three successive
+// box blurs, returning a *FloatGray with the same bounds. The approximation
+// is poor for standard deviations much below 2, for which
+// ExactGaussianBlurFloat is better suited.
+func GaussianBlurFloat(sigma float64, img FloatChannel) *FloatGray {
+	return gaussianBlurFloat(DefaultScheduler, sigma, img)
+}
+
+// gaussianBlurFloat implements GaussianBlurFloat using the given Scheduler.
+func gaussianBlurFloat(s *Scheduler, sigma float64, img FloatChannel) *FloatGray {
+	if sigma <= 0 {
+		return floatCopy(s, img)
+	}
+
+	for _, radius := range gaussianBoxes(sigma, 3) {
+		img = boxBlurFloat(s, radius, img)
+	}
+	return img.(*FloatGray)
+}
+
+// GaussianBlurGray16 is GaussianBlurFloat for a Channel, rounding the result
+// to an *image.Gray16 only once all passes are complete.
+func GaussianBlurGray16(sigma float64, img Channel) *image.Gray16 {
+	return FloatToGray16(GaussianBlurFloat(sigma, ChannelToFloat(img)))
+}
+
+// GaussianBlur is GaussianBlurFloat for an ImageReader, blurring its colors
+// premultiplied by alpha and returning an *image.NRGBA64 with the same
+// bounds.
+func GaussianBlur(sigma float64, img ImageReader) *image.NRGBA64 {
+	return filterImage(img, true, func(c FloatChannel) *FloatGray {
+		return GaussianBlurFloat(sigma, c)
+	})
+}
+
+// gaussianKernel returns the weights of a Gaussian with the given standard
+// deviation, truncated at three standard deviations either side of the
+// centre.
+func gaussianKernel(sigma float64) []float64 {
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	for i := range kernel {
+		x := float64(i - radius)
+		kernel[i] = math.Exp(-x * x / (2 * sigma * sigma))
+	}
+	return kernel
+}
+
+// ExactGaussianBlurFloat concurrently blurs a FloatChannel with a Gaussian
+// with the given standard deviation, applied as separate horizontal and
+// vertical kernels, returning a *FloatGray with the same bounds. Near the
+// edges, the kernels are normalised over the values within the bounds.
+func ExactGaussianBlurFloat(sigma float64, img FloatChannel) *FloatGray {
+	return exactGaussianBlurFloat(DefaultScheduler, sigma, img)
+}
+
+// exactGaussianBlurFloat implements ExactGaussianBlurFloat using the given
+// Scheduler.
+func exactGaussianBlurFloat(s *Scheduler, sigma float64, img FloatChannel) *FloatGray {
+	if sigma <= 0 {
+		return floatCopy(s, img)
+	}
+
+	kernel := gaussianKernel(sigma)
+	radius := len(kernel) / 2
+	bounds := img.Bounds()
+
+	rows := NewFloatGray(bounds)
+	s.RowsRP(
+		RowsRP(1, func(rect image.Rectangle) {
+			y := rect.Min.Y
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				var sum, weight float64
+				for i, w := range kernel {
+					if xx := x + i - radius; xx >= bounds.Min.X && xx < bounds.Max.X {
+						sum += w * float64(img.FloatAt(xx, y))
+						weight += w
+					}
+				}
+				rows.SetFloat(x, y, float32(sum/weight))
+			}
+		}),
+	)(bounds)
+
+	columns := NewFloatGray(bounds)
+	s.ColumnsRP(
+		ColumnsRP(1, func(rect image.Rectangle) {
+			x := rect.Min.X
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				var sum, weight float64
+				for i, w := range kernel {
+					if yy := y + i - radius; yy >= bounds.Min.Y && yy < bounds.Max.Y {
+						sum += w * float64(rows.FloatAt(x, yy))
+						weight += w
+					}
+				}
+				columns.SetFloat(x, y, float32(sum/weight))
+			}
+		}),
+	)(bounds)
+
+	return columns
+}
+
+// ExactGaussianBlurGray16 is ExactGaussianBlurFloat for a Channel, rounding
+// the result to an *image.Gray16.
+func ExactGaussianBlurGray16(sigma float64, img Channel) *image.Gray16 {
+	return FloatToGray16(ExactGaussianBlurFloat(sigma, ChannelToFloat(img)))
+}
+
+// ExactGaussianBlur is ExactGaussianBlurFloat for an ImageReader, blurring
+// its colors premultiplied by alpha and returning an *image.NRGBA64 with the
+// same bounds.
+func ExactGaussianBlur(sigma float64, img ImageReader) *image.NRGBA64 {
+	return filterImage(img, true, func(c FloatChannel) *FloatGray {
+		return ExactGaussianBlurFloat(sigma, c)
+	})
+}
+
+// filterImage filters each component of the colors of an ImageReader,
+// premultiplied by alpha so that transparent pixels don't bleed into their
+// neighbours, and returns the result as an *image.NRGBA64. If alpha is false,
+// the alpha component is left unfiltered.
+func filterImage(img ImageReader, alpha bool, filter func(FloatChannel) *FloatGray) *image.NRGBA64 {
+	bounds := img.Bounds()
+	planes := NewFloatPlanar(bounds)
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				r, g, b, a := img.At(pt.X, pt.Y).RGBA()
+				i := planes.PlaneOffset(pt.X, pt.Y)
+				planes.Planes[0][i] = float32(r) / math.MaxUint16
+				planes.Planes[1][i] = float32(g) / math.MaxUint16
+				planes.Planes[2][i] = float32(b) / math.MaxUint16
+				planes.Planes[3][i] = float32(a) / math.MaxUint16
+			},
+		),
+	)(bounds)
+
+	var filtered [4]*FloatGray
+	for i := range filtered {
+		if i < 3 || alpha {
+			filtered[i] = filter(planes.Channel(i))
+		} else {
+			filtered[i] = planes.Channel(i)
+		}
+	}
+
+	dst := image.NewNRGBA64(bounds)
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				a := floatToUint16(filtered[3].FloatAt(pt.X, pt.Y))
+				premultiplied := func(c *FloatGray) uint16 {
+					if v := floatToUint16(c.FloatAt(pt.X, pt.Y)); v < a {
+						return v
+					}
+					return a
+				}
+
+				dst.Set(pt.X, pt.Y, color.RGBA64{
+					R: premultiplied(filtered[0]),
+					G: premultiplied(filtered[1]),
+					B: premultiplied(filtered[2]),
+					A: a,
+				})
+			},
+		),
+	)(bounds)
+
+	return dst
+}
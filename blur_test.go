@@ -0,0 +1,152 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestBoxBlurFloat(t *testing.T) {
+	f := NewFloatGray(image.Rect(-5, 3, 15, 23))
+	f.SetFloat(4, 12, 9)
+
+	blurred := BoxBlurFloat(1, f)
+	if blurred.Bounds() != f.Bounds() {
+		t.Errorf("blurred bounds %v, expected %v", blurred.Bounds(), f.Bounds())
+	}
+	AllPointsRP(
+		func(pt image.Point) {
+			var expected float32
+			if pt.X >= 3 && pt.X <= 5 && pt.Y >= 11 && pt.Y <= 13 {
+				expected = 1
+			}
+			if v := blurred.FloatAt(pt.X, pt.Y); math.Abs(float64(v-expected)) > 1e-6 {
+				t.Errorf("blurred value at %v is %v, expected %v", pt, v, expected)
+			}
+		},
+	)(f.Rect)
+
+	// Near the edges, only values within the bounds are averaged.
+	f = NewFloatGray(image.Rect(0, 0, 3, 1))
+	copy(f.Pix, []float32{3, 6, 0})
+	blurred = BoxBlurFloat(1, f)
+	for x, expected := range []float32{4.5, 3, 3} {
+		if v := blurred.FloatAt(x, 0); v != expected {
+			t.Errorf("blurred value at %d is %v, expected %v", x, v, expected)
+		}
+	}
+}
+
+func TestGaussianBlurFloat(t *testing.T) {
+	gray := ChannelToFloat(ConvertToGray16(randomNRGBA64(copyTestRect)))
+
+	for _, sigma := range []float64{2, 3.5, 6} {
+		approx := GaussianBlurFloat(sigma, gray)
+		exact := ExactGaussianBlurFloat(sigma, gray)
+		if approx.Bounds() != gray.Bounds() || exact.Bounds() != gray.Bounds() {
+			t.Errorf("blurs with sigma %v have bounds %v and %v, expected %v",
+				sigma, approx.Bounds(), exact.Bounds(), gray.Bounds())
+		}
+
+		var worst float64
+		AllPointsRP(
+			func(pt image.Point) {
+				worst = math.Max(worst, math.Abs(float64(approx.FloatAt(pt.X, pt.Y)-exact.FloatAt(pt.X, pt.Y))))
+			},
+		)(gray.Rect)
+		if worst > 0.05 {
+			t.Errorf("approximate and exact blurs with sigma %v differ by %v", sigma, worst)
+		}
+	}
+
+	// The exact kernel should have the right standard deviation.
+	f := NewFloatGray(image.Rect(0, 0, 41, 1))
+	f.SetFloat(20, 0, 1)
+	blurred := ExactGaussianBlurFloat(3, f)
+	var sum, variance float64
+	for x := 0; x < 41; x++ {
+		v := float64(blurred.FloatAt(x, 0))
+		sum += v
+		variance += v * float64((x-20)*(x-20))
+	}
+	if math.Abs(sum-1) > 1e-6 || math.Abs(math.Sqrt(variance)-3) > 0.05 {
+		t.Errorf("exact blur of an impulse sums to %v with deviation %v", sum, math.Sqrt(variance))
+	}
+}
+
+func TestBlurGray16(t *testing.T) {
+	gray := ConvertToGray16(randomNRGBA64(copyTestRect))
+	for name, blur := range map[string]func(Channel) *image.Gray16{
+		"box":            func(c Channel) *image.Gray16 { return BoxBlurGray16(3, c) },
+		"Gaussian":       func(c Channel) *image.Gray16 { return GaussianBlurGray16(2, c) },
+		"exact Gaussian": func(c Channel) *image.Gray16 { return ExactGaussianBlurGray16(2, c) },
+	} {
+		if b := blur(gray); b.Bounds() != gray.Bounds() {
+			t.Errorf("%s blur has bounds %v, expected %v", name, b.Bounds(), gray.Bounds())
+		}
+
+		// A uniform channel shouldn't change.
+		uniform := image.NewGray16(copyTestRect)
+		AllPointsRP(
+			func(pt image.Point) {
+				uniform.SetGray16(pt.X, pt.Y, color.Gray16{Y: 0x1234})
+			},
+		)(copyTestRect)
+		b := blur(uniform)
+		AllPointsRP(
+			func(pt image.Point) {
+				if v := b.Gray16At(pt.X, pt.Y).Y; v != 0x1234 {
+					t.Errorf("%s blur of a uniform channel is %#04x at %v", name, v, pt)
+				}
+			},
+		)(copyTestRect)
+	}
+}
+
+func TestBlurPremultiplied(t *testing.T) {
+
+	// Transparent red pixels shouldn't bleed into opaque blue ones.
+	src := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	AllPointsRP(
+		func(pt image.Point) {
+			if pt.X < 10 {
+				src.SetNRGBA(pt.X, pt.Y, color.NRGBA{R: 0xff})
+			} else {
+				src.SetNRGBA(pt.X, pt.Y, color.NRGBA{B: 0xff, A: 0xff})
+			}
+		},
+	)(src.Rect)
+
+	for name, blur := range map[string]func(ImageReader) *image.NRGBA64{
+		"box":            func(img ImageReader) *image.NRGBA64 { return BoxBlur(2, img) },
+		"Gaussian":       func(img ImageReader) *image.NRGBA64 { return GaussianBlur(1.5, img) },
+		"exact Gaussian": func(img ImageReader) *image.NRGBA64 { return ExactGaussianBlur(1.5, img) },
+	} {
+		dst := blur(src)
+		if dst.Rect != src.Rect {
+			t.Errorf("%s blur has bounds %v, expected %v", name, dst.Rect, src.Rect)
+		}
+
+		for x := 0; x < 20; x++ {
+			c := dst.NRGBA64At(x, 10)
+			if c.A != 0 && (c.R != 0 || c.B != math.MaxUint16) {
+				t.Errorf("%s blur is %v at %d, expected blue", name, c, x)
+			}
+		}
+	}
+}
+
+func BenchmarkGaussianBlurFloat(b *testing.B) {
+	gray := ChannelToFloat(ConvertToGray16(randomNRGBA64(image.Rect(0, 0, 256, 256))))
+	for i := 0; i < b.N; i++ {
+		GaussianBlurFloat(5, gray)
+	}
+}
+
+func BenchmarkExactGaussianBlurFloat(b *testing.B) {
+	gray := ChannelToFloat(ConvertToGray16(randomNRGBA64(image.Rect(0, 0, 256, 256))))
+	for i := 0; i < b.N; i++ {
+		ExactGaussianBlurFloat(5, gray)
+	}
+}
@@ -0,0 +1,80 @@
+package imageutil
+
+// Border is a way of treating the coordinates outside the bounds of an image
+// that a filter's window reaches.
+type Border int
+
+const (
+	// BorderClamp uses the value of the nearest pixel within the bounds.
+	BorderClamp Border = iota
+
+	// BorderMirror reflects the coordinates about the outermost pixels within
+	// the bounds, without repeating them.
+	BorderMirror
+
+	// BorderWrap wraps the coordinates around to the opposite side of the
+	// bounds.
+	BorderWrap
+
+	// BorderZero uses zero.
+	BorderZero
+
+	// BorderShrink leaves the coordinates out, shrinking the window to the
+	// bounds.
+	BorderShrink
+)
+
+// index maps a coordinate i to one between min and max, excluding max, and
+// reports whether the coordinate should be included in the window. If it
+// should be included but not mapped, as with BorderZero, the index returned
+// is outside the range.
+func (b Border) index(i, min, max int) (int, bool) {
+	if i >= min && i < max {
+		return i, true
+	}
+
+	n := max - min
+	switch b {
+	case BorderClamp:
+		if i < min {
+			return min, true
+		}
+		return max - 1, true
+	case BorderMirror:
+		if n == 1 {
+			return min, true
+		}
+		period := 2 * (n - 1)
+		j := (i - min) % period
+		if j < 0 {
+			j += period
+		}
+		if j >= n {
+			j = period - j
+		}
+		return min + j, true
+	case BorderWrap:
+		j := (i - min) % n
+		if j < 0 {
+			j += n
+		}
+		return min + j, true
+	case BorderZero:
+		return i, true
+	}
+	return i, false
+}
+
+// sample returns the value at coordinate i of a line of values between min
+// and max, excluding max, read using get, and reports whether it should be
+// included in the window.
+func (b Border) sample(i, min, max int, get func(i int) uint16) (uint16, bool) {
+	j, ok := b.index(i, min, max)
+	if !ok {
+		return 0, false
+	}
+	if j < min || j >= max {
+		return 0, true
+	}
+	return get(j), true
+}
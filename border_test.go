@@ -0,0 +1,37 @@
+package imageutil
+
+import (
+	"testing"
+)
+
+func TestBorderIndex(t *testing.T) {
+	const min, max = 2, 6
+
+	for border, expected := range map[Border][]int{
+		BorderClamp:  {2, 2, 2, 2, 2, 3, 4, 5, 5, 5},
+		BorderMirror: {4, 5, 4, 3, 2, 3, 4, 5, 4, 3},
+		BorderWrap:   {2, 3, 4, 5, 2, 3, 4, 5, 2, 3},
+		BorderZero:   {-2, -1, 0, 1, 2, 3, 4, 5, 6, 7},
+		BorderShrink: {-2, -1, 0, 1, 2, 3, 4, 5, 6, 7},
+	} {
+		for k, e := range expected {
+			i := k - 2
+			j, ok := border.index(i, min, max)
+			if j != e {
+				t.Errorf("border %d mapped %d to %d, expected %d", border, i, j, e)
+			}
+			if inside := i >= min && i < max; ok != (inside || border != BorderShrink) {
+				t.Errorf("border %d included %d: %v", border, i, ok)
+			}
+		}
+	}
+
+	// A single pixel is repeated by all borders that map coordinates.
+	for _, border := range []Border{BorderClamp, BorderMirror, BorderWrap} {
+		for i := -3; i < 4; i++ {
+			if j, _ := border.index(i, 0, 1); j != 0 {
+				t.Errorf("border %d mapped %d to %d for a single pixel", border, i, j)
+			}
+		}
+	}
+}
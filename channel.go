@@ -3,6 +3,7 @@ package imageutil
 import (
 	"image"
 	"image/color"
+	"math"
 )
 
 // Channel is any object that implements ImageReader as well as providing a
@@ -20,44 +21,10 @@ type channel struct {
 }
 
 // Channels decomposes a given NRGBA64 into red, green, blue, and alpha Channels.
+// The Channels are views of the image, as returned by NRGBA64Channels, so
+// they also implement WritableChannel.
 func NRGBA64ToChannels(img *image.NRGBA64) (r, g, b, a Channel) {
-	r = channel{
-		bounds: img.Bounds,
-		gray16At: func(x, y int) color.Gray16 {
-			return color.Gray16{
-				Y: img.NRGBA64At(x, y).R,
-			}
-		},
-	}
-
-	g = channel{
-		bounds: img.Bounds,
-		gray16At: func(x, y int) color.Gray16 {
-			return color.Gray16{
-				Y: img.NRGBA64At(x, y).G,
-			}
-		},
-	}
-
-	b = channel{
-		bounds: img.Bounds,
-		gray16At: func(x, y int) color.Gray16 {
-			return color.Gray16{
-				Y: img.NRGBA64At(x, y).B,
-			}
-		},
-	}
-
-	a = channel{
-		bounds: img.Bounds,
-		gray16At: func(x, y int) color.Gray16 {
-			return color.Gray16{
-				Y: img.NRGBA64At(x, y).A,
-			}
-		},
-	}
-
-	return
+	return NRGBA64Channels(img)
 }
 
 func (c channel) Bounds() image.Rectangle {
@@ -77,9 +44,14 @@ func (c channel) ColorModel() color.Model {
 }
 
 func ChannelsToNRGBA64(r, g, b, a Channel) *image.NRGBA64 {
+	return channelsToNRGBA64(DefaultScheduler, r, g, b, a)
+}
+
+// channelsToNRGBA64 implements ChannelsToNRGBA64 using the given Scheduler.
+func channelsToNRGBA64(s *Scheduler, r, g, b, a Channel) *image.NRGBA64 {
 	bounds := r.Bounds().Union(g.Bounds()).Union(b.Bounds()).Union(a.Bounds())
 	img := image.NewNRGBA64(bounds)
-	QuickRP(
+	s.RP(
 		AllPointsRP(
 			func(pt image.Point) {
 				img.Set(pt.X, pt.Y,
@@ -96,3 +68,314 @@ func ChannelsToNRGBA64(r, g, b, a Channel) *image.NRGBA64 {
 
 	return img
 }
+
+// componentChannels returns four Channels with the bounds of an ImageReader
+// whose values are the respective components that f returns for the pixel at
+// the same coordinates.
+func componentChannels(img ImageReader, f func(c color.Color) [4]uint16) (c [4]Channel) {
+	for i := range c {
+		i := i
+		c[i] = channel{
+			bounds: img.Bounds,
+			gray16At: func(x, y int) color.Gray16 {
+				return color.Gray16{
+					Y: f(img.At(x, y))[i],
+				}
+			},
+		}
+	}
+	return
+}
+
+// fromChannels concurrently calls set with the values of the given Channels
+// at each point of the given bounds.
+func fromChannels(bounds image.Rectangle, set func(pt image.Point, v [4]uint16), cs ...Channel) {
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				var v [4]uint16
+				for i, c := range cs {
+					v[i] = c.Gray16At(pt.X, pt.Y).Y
+				}
+				set(pt, v)
+			},
+		),
+	)(bounds)
+}
+
+// channelsBounds returns the union of the bounds of the given Channels.
+func channelsBounds(cs ...Channel) image.Rectangle {
+	var bounds image.Rectangle
+	for _, c := range cs {
+		bounds = bounds.Union(c.Bounds())
+	}
+	return bounds
+}
+
+// RGBA64ToChannels decomposes an ImageReader into alpha-premultiplied red,
+// green, blue and alpha Channels. The Channels of an *image.RGBA64 are views
+// of it, as returned by RGBA64Channels.
+func RGBA64ToChannels(img ImageReader) (r, g, b, a Channel) {
+	if img, ok := img.(*image.RGBA64); ok {
+		return RGBA64Channels(img)
+	}
+
+	c := componentChannels(img, func(c color.Color) [4]uint16 {
+		r, g, b, a := c.RGBA()
+		return [4]uint16{uint16(r), uint16(g), uint16(b), uint16(a)}
+	})
+	return c[0], c[1], c[2], c[3]
+}
+
+// ChannelsToRGBA64 concurrently recomposes alpha-premultiplied red, green,
+// blue and alpha Channels into an *image.RGBA64.
+func ChannelsToRGBA64(r, g, b, a Channel) *image.RGBA64 {
+	img := image.NewRGBA64(channelsBounds(r, g, b, a))
+	fromChannels(
+		img.Rect,
+		func(pt image.Point, v [4]uint16) {
+			img.SetRGBA64(pt.X, pt.Y, color.RGBA64{R: v[0], G: v[1], B: v[2], A: v[3]})
+		},
+		r, g, b, a,
+	)
+	return img
+}
+
+// GrayAlphaToChannels decomposes an ImageReader into Channels of the
+// luminance of its colors, without alpha premultiplication, and alpha.
+func GrayAlphaToChannels(img ImageReader) (y, a Channel) {
+	c := componentChannels(img, func(c color.Color) [4]uint16 {
+		c1 := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+		a := c1.A
+		c1.A = math.MaxUint16
+		return [4]uint16{color.Gray16Model.Convert(c1).(color.Gray16).Y, a}
+	})
+	return c[0], c[1]
+}
+
+// ChannelsToGrayAlpha concurrently recomposes luminance and alpha Channels
+// into an *image.NRGBA64 with gray colors.
+func ChannelsToGrayAlpha(y, a Channel) *image.NRGBA64 {
+	img := image.NewNRGBA64(channelsBounds(y, a))
+	fromChannels(
+		img.Rect,
+		func(pt image.Point, v [4]uint16) {
+			img.SetNRGBA64(pt.X, pt.Y, color.NRGBA64{R: v[0], G: v[0], B: v[0], A: v[1]})
+		},
+		y, a,
+	)
+	return img
+}
+
+// YCbCrToChannels decomposes an *image.YCbCr into luma and chroma Channels
+// at their native resolutions. The luma Channel has the bounds of the image,
+// while under chroma subsampling the chroma Channels are smaller, with the
+// sample shared by the pixel at (x, y) at (x/rx, y/ry), where rx and ry are
+// the number of columns and rows of pixels that share each sample. The
+// Channels are views of the planes, as returned by YCbCrChannels.
+func YCbCrToChannels(img *image.YCbCr) (y, cb, cr Channel) {
+	return YCbCrChannels(img)
+}
+
+// ChannelsToYCbCr concurrently recomposes luma and chroma Channels, laid out
+// as by YCbCrToChannels, into a *YCbCr with the bounds of the luma Channel
+// and the given subsampling ratio.
+func ChannelsToYCbCr(y, cb, cr Channel, ratio image.YCbCrSubsampleRatio) *YCbCr {
+	bounds := y.Bounds()
+	img := image.NewYCbCr(bounds, ratio)
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				img.Y[img.YOffset(pt.X, pt.Y)] = uint8(y.Gray16At(pt.X, pt.Y).Y >> 8)
+			},
+		),
+	)(bounds)
+
+	rect := chromaRect(bounds, ratio)
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				i := (pt.Y-rect.Min.Y)*img.CStride + (pt.X - rect.Min.X)
+				if i < len(img.Cb) {
+					img.Cb[i] = uint8(cb.Gray16At(pt.X, pt.Y).Y >> 8)
+					img.Cr[i] = uint8(cr.Gray16At(pt.X, pt.Y).Y >> 8)
+				}
+			},
+		),
+	)(rect)
+
+	return &YCbCr{img}
+}
+
+// CMYKToChannels decomposes an ImageReader into cyan, magenta, yellow and
+// black Channels. The Channels of an *image.CMYK are views of it, as returned
+// by CMYKChannels.
+func CMYKToChannels(img ImageReader) (c, m, y, k Channel) {
+	if img, ok := img.(*image.CMYK); ok {
+		return CMYKChannels(img)
+	}
+
+	cs := componentChannels(img, func(c color.Color) [4]uint16 {
+		c1 := color.CMYKModel.Convert(c).(color.CMYK)
+		return [4]uint16{
+			uint16(c1.C) * 0x101,
+			uint16(c1.M) * 0x101,
+			uint16(c1.Y) * 0x101,
+			uint16(c1.K) * 0x101,
+		}
+	})
+	return cs[0], cs[1], cs[2], cs[3]
+}
+
+// ChannelsToCMYK concurrently recomposes cyan, magenta, yellow and black
+// Channels into an *image.CMYK.
+func ChannelsToCMYK(c, m, y, k Channel) *image.CMYK {
+	img := image.NewCMYK(channelsBounds(c, m, y, k))
+	fromChannels(
+		img.Rect,
+		func(pt image.Point, v [4]uint16) {
+			img.SetCMYK(pt.X, pt.Y, color.CMYK{
+				C: uint8(v[0] >> 8),
+				M: uint8(v[1] >> 8),
+				Y: uint8(v[2] >> 8),
+				K: uint8(v[3] >> 8),
+			})
+		},
+		c, m, y, k,
+	)
+	return img
+}
+
+// nrgb returns the components of a color without alpha premultiplication,
+// between 0 and 1, and its alpha.
+func nrgb(c color.Color) (rgb, uint16) {
+	c1 := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+	return rgb{
+		float64(c1.R) / math.MaxUint16,
+		float64(c1.G) / math.MaxUint16,
+		float64(c1.B) / math.MaxUint16,
+	}, c1.A
+}
+
+// nrgbaFromChannels concurrently sets the pixels of a new *image.NRGBA64 from
+// three Channels, using f to convert their values to a color, and an alpha
+// Channel.
+func nrgbaFromChannels(f func(v [4]uint16) rgb, c0, c1, c2, a Channel) *image.NRGBA64 {
+	img := image.NewNRGBA64(channelsBounds(c0, c1, c2, a))
+	fromChannels(
+		img.Rect,
+		func(pt image.Point, v [4]uint16) {
+			c := f(v)
+			img.SetNRGBA64(pt.X, pt.Y, color.NRGBA64{
+				R: clampUint16(c[0] * math.MaxUint16),
+				G: clampUint16(c[1] * math.MaxUint16),
+				B: clampUint16(c[2] * math.MaxUint16),
+				A: v[3],
+			})
+		},
+		c0, c1, c2, a,
+	)
+	return img
+}
+
+// HSVToChannels decomposes an ImageReader into hue, saturation, value and
+// alpha Channels. Hue, saturation and value are scaled from between 0 and 1
+// to between 0 and 0xffff.
+func HSVToChannels(img ImageReader) (h, s, v, a Channel) {
+	c := componentChannels(img, func(c color.Color) [4]uint16 {
+		c1, a := nrgb(c)
+		h, s, v := rgbToHSV(c1)
+		return [4]uint16{
+			clampUint16(h * math.MaxUint16),
+			clampUint16(s * math.MaxUint16),
+			clampUint16(v * math.MaxUint16),
+			a,
+		}
+	})
+	return c[0], c[1], c[2], c[3]
+}
+
+// ChannelsToHSV concurrently recomposes hue, saturation, value and alpha
+// Channels, scaled as by HSVToChannels, into an *image.NRGBA64.
+func ChannelsToHSV(h, s, v, a Channel) *image.NRGBA64 {
+	return nrgbaFromChannels(
+		func(v [4]uint16) rgb {
+			return hsvToRGB(
+				float64(v[0])/math.MaxUint16,
+				float64(v[1])/math.MaxUint16,
+				float64(v[2])/math.MaxUint16,
+			)
+		},
+		h, s, v, a,
+	)
+}
+
+// HSLToChannels decomposes an ImageReader into hue, saturation, lightness
+// and alpha Channels. Hue, saturation and lightness are scaled from between 0
+// and 1 to between 0 and 0xffff.
+func HSLToChannels(img ImageReader) (h, s, l, a Channel) {
+	c := componentChannels(img, func(c color.Color) [4]uint16 {
+		c1, a := nrgb(c)
+		h, s, l := rgbToHSL(c1)
+		return [4]uint16{
+			clampUint16(h * math.MaxUint16),
+			clampUint16(s * math.MaxUint16),
+			clampUint16(l * math.MaxUint16),
+			a,
+		}
+	})
+	return c[0], c[1], c[2], c[3]
+}
+
+// ChannelsToHSL concurrently recomposes hue, saturation, lightness and alpha
+// Channels, scaled as by HSLToChannels, into an *image.NRGBA64.
+func ChannelsToHSL(h, s, l, a Channel) *image.NRGBA64 {
+	return nrgbaFromChannels(
+		func(v [4]uint16) rgb {
+			return hslToRGB(
+				float64(v[0])/math.MaxUint16,
+				float64(v[1])/math.MaxUint16,
+				float64(v[2])/math.MaxUint16,
+			)
+		},
+		h, s, l, a,
+	)
+}
+
+// labScale is the scale from the a* and b* coordinates of CIE L*a*b*, which
+// are offset by 128, to Channel values.
+const labScale = 256
+
+// LabToChannels decomposes an ImageReader, taken to be in sRGB, into CIE
+// L*a*b* lightness, a* and b* Channels under illuminant D65, and an alpha
+// Channel. L* is scaled from between 0 and 100 to between 0 and 0xffff, while
+// a* and b* are offset by 128 and multiplied by 256, clamping them between
+// -128 and 128.
+func LabToChannels(img ImageReader) (l, a, b, alpha Channel) {
+	c := componentChannels(img, func(c color.Color) [4]uint16 {
+		c1, alpha := nrgb(c)
+		l, a, b := rgbToLab(c1)
+		return [4]uint16{
+			clampUint16(l / 100 * math.MaxUint16),
+			clampUint16((a + 128) * labScale),
+			clampUint16((b + 128) * labScale),
+			alpha,
+		}
+	})
+	return c[0], c[1], c[2], c[3]
+}
+
+// ChannelsToLab concurrently recomposes CIE L*a*b* lightness, a*, b* and
+// alpha Channels, scaled as by LabToChannels, into an *image.NRGBA64 in sRGB.
+func ChannelsToLab(l, a, b, alpha Channel) *image.NRGBA64 {
+	return nrgbaFromChannels(
+		func(v [4]uint16) rgb {
+			return labToRGB(
+				float64(v[0])/math.MaxUint16*100,
+				float64(v[1])/labScale-128,
+				float64(v[2])/labScale-128,
+			)
+		},
+		l, a, b, alpha,
+	)
+}
@@ -0,0 +1,139 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRGBA64ToChannels(t *testing.T) {
+	src := ConvertToRGBA64(randomNRGBA64(copyTestRect))
+	dst := ChannelsToRGBA64(RGBA64ToChannels(src))
+	if dst.Rect != src.Rect || !bytes.Equal(dst.Pix, src.Pix) {
+		t.Error("RGBA64 channels don't recompose to the original image")
+	}
+}
+
+func TestGrayAlphaToChannels(t *testing.T) {
+	gray := ConvertToGray16(randomNRGBA64(copyTestRect))
+	alpha := ConvertToAlpha16(randomNRGBA64(copyTestRect))
+	src := image.NewNRGBA64(copyTestRect)
+	AllPointsRP(
+		func(pt image.Point) {
+			y := gray.Gray16At(pt.X, pt.Y).Y
+			src.SetNRGBA64(pt.X, pt.Y, color.NRGBA64{R: y, G: y, B: y, A: alpha.Alpha16At(pt.X, pt.Y).A})
+		},
+	)(copyTestRect)
+
+	dst := ChannelsToGrayAlpha(GrayAlphaToChannels(src))
+	if dst.Rect != src.Rect || !bytes.Equal(dst.Pix, src.Pix) {
+		t.Error("gray and alpha channels don't recompose to the original image")
+	}
+}
+
+func TestYCbCrToChannels(t *testing.T) {
+	for _, ratio := range []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio422,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440,
+		image.YCbCrSubsampleRatio411,
+		image.YCbCrSubsampleRatio410,
+	} {
+		full := randomYCbCr(image.Rect(0, 0, 100, 80), ratio)
+		for _, src := range []*image.YCbCr{
+			full,
+			full.SubImage(image.Rect(3, 5, 61, 47)).(*image.YCbCr),
+		} {
+			y, cb, cr := YCbCrToChannels(src)
+			if y.Bounds() != src.Rect {
+				t.Errorf("%v luma channel has bounds %v, expected %v", ratio, y.Bounds(), src.Rect)
+			}
+			if expected := chromaRect(src.Rect, ratio); cb.Bounds() != expected || cr.Bounds() != expected {
+				t.Errorf("%v chroma channels have bounds %v, expected %v", ratio, cb.Bounds(), expected)
+			}
+
+			dst := ChannelsToYCbCr(y, cb, cr, ratio)
+			AllPointsRP(
+				func(pt image.Point) {
+					if dst.YCbCrAt(pt.X, pt.Y) != src.YCbCrAt(pt.X, pt.Y) {
+						t.Errorf("%v channels recomposed to %v at %v, expected %v",
+							ratio, dst.YCbCrAt(pt.X, pt.Y), pt, src.YCbCrAt(pt.X, pt.Y))
+					}
+				},
+			)(src.Rect)
+		}
+	}
+}
+
+func TestCMYKToChannels(t *testing.T) {
+	src := image.NewCMYK(copyTestRect)
+	Copy(src, randomNRGBA64(copyTestRect))
+	dst := ChannelsToCMYK(CMYKToChannels(src))
+	if dst.Rect != src.Rect || !bytes.Equal(dst.Pix, src.Pix) {
+		t.Error("CMYK channels don't recompose to the original image")
+	}
+}
+
+func TestColorSpaceChannels(t *testing.T) {
+	src := randomNRGBA64(copyTestRect).(*image.NRGBA64)
+
+	for name, roundTrip := range map[string]func(ImageReader) *image.NRGBA64{
+		"HSV": func(img ImageReader) *image.NRGBA64 { return ChannelsToHSV(HSVToChannels(img)) },
+		"HSL": func(img ImageReader) *image.NRGBA64 { return ChannelsToHSL(HSLToChannels(img)) },
+		"Lab": func(img ImageReader) *image.NRGBA64 { return ChannelsToLab(LabToChannels(img)) },
+	} {
+		dst := roundTrip(src)
+		if dst.Rect != src.Rect {
+			t.Errorf("%s channels recomposed to bounds %v, expected %v", name, dst.Rect, src.Rect)
+		}
+
+		AllPointsRP(
+			func(pt image.Point) {
+				s, d := src.NRGBA64At(pt.X, pt.Y), dst.NRGBA64At(pt.X, pt.Y)
+				for _, v := range [][2]uint16{{s.R, d.R}, {s.G, d.G}, {s.B, d.B}, {s.A, d.A}} {
+					if diff := int(v[0]) - int(v[1]); diff > 0x100 || diff < -0x100 {
+						t.Errorf("%s channels recomposed to %v at %v, expected %v", name, d, pt, s)
+						return
+					}
+				}
+			},
+		)(src.Rect)
+	}
+}
+
+func TestColorSpaces(t *testing.T) {
+	for _, c := range []struct {
+		c       rgb
+		h, s, v float64
+		l       float64
+		lab     [3]float64
+	}{
+		{rgb{1, 0, 0}, 0, 1, 1, 0.5, [3]float64{53.24, 80.09, 67.20}},
+		{rgb{0, 1, 0}, 1.0 / 3, 1, 1, 0.5, [3]float64{87.73, -86.18, 83.18}},
+		{rgb{0, 0, 1}, 2.0 / 3, 1, 1, 0.5, [3]float64{32.30, 79.19, -107.86}},
+		{rgb{1, 1, 1}, 0, 0, 1, 1, [3]float64{100, 0, 0}},
+		{rgb{0.5, 0.25, 0.5}, 5.0 / 6, 0.5, 0.5, 0.375, [3]float64{37.36, 37.21, -24.21}},
+	} {
+		if h, s, v := rgbToHSV(c.c); !near(h, c.h) || !near(s, c.s) || !near(v, c.v) {
+			t.Errorf("%v has HSV %v, %v, %v, expected %v, %v, %v", c.c, h, s, v, c.h, c.s, c.v)
+		}
+		if h, s, l := rgbToHSL(c.c); !near(h, c.h) || !near(l, c.l) {
+			t.Errorf("%v has HSL %v, %v, %v, expected hue %v and lightness %v", c.c, h, s, l, c.h, c.l)
+		}
+
+		l, a, b := rgbToLab(c.c)
+		for i, v := range [3]float64{l, a, b} {
+			if d := v - c.lab[i]; d > 0.05 || d < -0.05 {
+				t.Errorf("%v has L*a*b* %v, %v, %v, expected %v", c.c, l, a, b, c.lab)
+				break
+			}
+		}
+	}
+}
+
+// near reports whether two values differ by less than 1e-9.
+func near(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
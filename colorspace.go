@@ -0,0 +1,163 @@
+package imageutil
+
+import (
+	"math"
+)
+
+// hue returns the hue of a color between 0 and 1, along with its largest and
+// smallest components.
+func hue(c rgb) (h, max, min float64) {
+	max = math.Max(c[0], math.Max(c[1], c[2]))
+	min = math.Min(c[0], math.Min(c[1], c[2]))
+
+	d := max - min
+	switch {
+	case d == 0:
+		return 0, max, min
+	case max == c[0]:
+		h = (c[1] - c[2]) / d
+		if h < 0 {
+			h += 6
+		}
+	case max == c[1]:
+		h = (c[2]-c[0])/d + 2
+	default:
+		h = (c[0]-c[1])/d + 4
+	}
+	return h / 6, max, min
+}
+
+// fromHue returns the color with hue h between 0 and 1, chroma c and the
+// given smallest component.
+func fromHue(h, c, min float64) rgb {
+	h = math.Mod(h, 1) * 6
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+
+	var r rgb
+	switch {
+	case h < 1:
+		r = rgb{c, x, 0}
+	case h < 2:
+		r = rgb{x, c, 0}
+	case h < 3:
+		r = rgb{0, c, x}
+	case h < 4:
+		r = rgb{0, x, c}
+	case h < 5:
+		r = rgb{x, 0, c}
+	default:
+		r = rgb{c, 0, x}
+	}
+
+	for i := range r {
+		r[i] += min
+	}
+	return r
+}
+
+// rgbToHSV returns the hue, saturation and value of a color, all between 0
+// and 1.
+func rgbToHSV(c rgb) (h, s, v float64) {
+	h, max, min := hue(c)
+	if max > 0 {
+		s = (max - min) / max
+	}
+	return h, s, max
+}
+
+// hsvToRGB returns the color with the given hue, saturation and value.
+func hsvToRGB(h, s, v float64) rgb {
+	c := v * s
+	return fromHue(h, c, v-c)
+}
+
+// rgbToHSL returns the hue, saturation and lightness of a color, all between
+// 0 and 1.
+func rgbToHSL(c rgb) (h, s, l float64) {
+	h, max, min := hue(c)
+	l = (max + min) / 2
+	if d := 1 - math.Abs(2*l-1); d > 0 {
+		s = (max - min) / d
+	}
+	return h, s, l
+}
+
+// hslToRGB returns the color with the given hue, saturation and lightness.
+func hslToRGB(h, s, l float64) rgb {
+	c := (1 - math.Abs(2*l-1)) * s
+	return fromHue(h, c, l-c/2)
+}
+
+// The white point of CIE standard illuminant D65 in CIE XYZ.
+const (
+	whiteX = 0.95047
+	whiteY = 1
+	whiteZ = 1.08883
+)
+
+// labDelta is the point at which the CIE L*a*b* transfer function becomes
+// linear.
+const labDelta = 6.0 / 29
+
+// srgbToLinear returns the linear intensity of an sRGB component.
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB returns the sRGB component of a linear intensity.
+func linearToSRGB(c float64) float64 {
+	if c <= 0.0031308 {
+		return 12.92 * c
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// labF is the CIE L*a*b* transfer function.
+func labF(t float64) float64 {
+	if t > labDelta*labDelta*labDelta {
+		return math.Cbrt(t)
+	}
+	return t/(3*labDelta*labDelta) + 4.0/29
+}
+
+// labFInverse is the inverse of labF.
+func labFInverse(t float64) float64 {
+	if t > labDelta {
+		return t * t * t
+	}
+	return 3 * labDelta * labDelta * (t - 4.0/29)
+}
+
+// rgbToLab returns the CIE L*a*b* coordinates of an sRGB color under
+// illuminant D65, with L* between 0 and 100.
+func rgbToLab(c rgb) (l, a, b float64) {
+	r, g, bl := srgbToLinear(c[0]), srgbToLinear(c[1]), srgbToLinear(c[2])
+
+	fx := labF((0.4124564*r + 0.3575761*g + 0.1804375*bl) / whiteX)
+	fy := labF((0.2126729*r + 0.7151522*g + 0.0721750*bl) / whiteY)
+	fz := labF((0.0193339*r + 0.1191920*g + 0.9503041*bl) / whiteZ)
+
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// labToRGB returns the sRGB color with the given CIE L*a*b* coordinates
+// under illuminant D65, clipping colors outside the sRGB gamut.
+func labToRGB(l, a, b float64) rgb {
+	fy := (l + 16) / 116
+	x := labFInverse(fy+a/500) * whiteX
+	y := labFInverse(fy) * whiteY
+	z := labFInverse(fy-b/200) * whiteZ
+
+	c := rgb{
+		3.2404542*x - 1.5371385*y - 0.4985314*z,
+		-0.9692660*x + 1.8760108*y + 0.0415560*z,
+		0.0556434*x - 0.2040259*y + 1.0572252*z,
+	}
+	for i := range c {
+		c[i] = math.Max(0, math.Min(1, linearToSRGB(math.Max(0, c[i]))))
+	}
+	return c
+}
@@ -0,0 +1,150 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+)
+
+// CompositeOp is a Porter-Duff compositing operator, combining a source
+// color with a destination color.
+type CompositeOp int
+
+const (
+	// CompositeClear clears the destination.
+	CompositeClear CompositeOp = iota
+
+	// CompositeSrc replaces the destination with the source.
+	CompositeSrc
+
+	// CompositeDst leaves the destination unchanged.
+	CompositeDst
+
+	// CompositeOver places the source over the destination.
+	CompositeOver
+
+	// CompositeDstOver places the destination over the source.
+	CompositeDstOver
+
+	// CompositeIn keeps the source where the destination is opaque.
+	CompositeIn
+
+	// CompositeDstIn keeps the destination where the source is opaque.
+	CompositeDstIn
+
+	// CompositeOut keeps the source where the destination is transparent.
+	CompositeOut
+
+	// CompositeDstOut keeps the destination where the source is transparent.
+	CompositeDstOut
+
+	// CompositeAtop places the source over the destination, keeping only
+	// the parts that lie within the destination.
+	CompositeAtop
+
+	// CompositeDstAtop places the destination over the source, keeping only
+	// the parts that lie within the source.
+	CompositeDstAtop
+
+	// CompositeXor keeps the source and the destination where they don't
+	// overlap.
+	CompositeXor
+)
+
+// factors returns the fractions of the source and destination, scaled to
+// 0xffff, that the operator keeps given the source and destination alphas.
+func (op CompositeOp) factors(sa, da uint32) (fs, fd uint32) {
+	const m = 0xffff
+
+	switch op {
+	case CompositeSrc:
+		return m, 0
+	case CompositeDst:
+		return 0, m
+	case CompositeOver:
+		return m, m - sa
+	case CompositeDstOver:
+		return m - da, m
+	case CompositeIn:
+		return da, 0
+	case CompositeDstIn:
+		return 0, sa
+	case CompositeOut:
+		return m - da, 0
+	case CompositeDstOut:
+		return 0, m - sa
+	case CompositeAtop:
+		return da, m - sa
+	case CompositeDstAtop:
+		return m - da, sa
+	case CompositeXor:
+		return m - da, m - sa
+	}
+	return 0, 0
+}
+
+// Composite concurrently combines each pixel of a source ImageReader with the
+// pixel at the same coordinates of a destination ImageReadWriter using the
+// given operator, over the intersection of their bounds.
+func Composite(dst ImageReadWriter, src ImageReader, op CompositeOp) {
+	CompositeMask(dst, src, nil, 1, op)
+}
+
+// CompositeMask is Composite, limiting the effect of the operator by the
+// alpha of a mask ImageReader, which may be nil, and a global opacity between
+// 0 and 1. Where the mask and opacity combine to m, the destination becomes
+// op(src, dst)*m + dst*(1-m), so with CompositeOver this matches
+// draw.DrawMask. The mask uses the same coordinates as the destination and
+// also clips the pixels composited.
+func CompositeMask(dst ImageReadWriter, src, mask ImageReader, opacity float64, op CompositeOp) {
+	const m = 0xffff
+
+	if opacity <= 0 || op == CompositeDst {
+		return
+	}
+	if opacity > 1 {
+		opacity = 1
+	}
+	o := uint32(opacity*m + 0.5)
+
+	r := dst.Bounds().Intersect(src.Bounds())
+	if mask != nil {
+		r = r.Intersect(mask.Bounds())
+	}
+
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				ma := o
+				if mask != nil {
+					_, _, _, a := mask.At(pt.X, pt.Y).RGBA()
+					ma = a * o / m
+				}
+				if ma == 0 {
+					return
+				}
+
+				sr, sg, sb, sa := src.At(pt.X, pt.Y).RGBA()
+				dr, dg, db, da := dst.At(pt.X, pt.Y).RGBA()
+				fs, fd := op.factors(sa, da)
+
+				// Apply the operator and interpolate between the result and the
+				// destination by the mask in a single step, rounding once.
+				composite := func(s, d uint32) uint16 {
+					c := uint64(s)*uint64(fs)*uint64(ma) + uint64(d)*(uint64(fd)*uint64(ma)+uint64(m-ma)*m)
+					c /= m * m
+					if c > m {
+						c = m
+					}
+					return uint16(c)
+				}
+
+				dst.Set(pt.X, pt.Y, color.RGBA64{
+					R: composite(sr, dr),
+					G: composite(sg, dg),
+					B: composite(sb, db),
+					A: composite(sa, da),
+				})
+			},
+		),
+	)(r)
+}
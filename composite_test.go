@@ -0,0 +1,79 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestComposite(t *testing.T) {
+	s := color.RGBA64{R: 0x4000, G: 0x1000, B: 0x0000, A: 0x8000}
+	d := color.RGBA64{R: 0x0000, G: 0x6000, B: 0x3000, A: 0xc000}
+
+	for op, f := range map[CompositeOp][2]float64{
+		CompositeClear:   {0, 0},
+		CompositeSrc:     {1, 0},
+		CompositeDst:     {0, 1},
+		CompositeOver:    {1, 0.5},
+		CompositeDstOver: {0.25, 1},
+		CompositeIn:      {0.75, 0},
+		CompositeDstIn:   {0, 0.5},
+		CompositeOut:     {0.25, 0},
+		CompositeDstOut:  {0, 0.5},
+		CompositeAtop:    {0.75, 0.5},
+		CompositeDstAtop: {0.25, 0.5},
+		CompositeXor:     {0.25, 0.5},
+	} {
+		for _, opacity := range []float64{1, 0.5} {
+			src := image.NewRGBA64(image.Rect(0, 0, 1, 1))
+			src.SetRGBA64(0, 0, s)
+			dst := image.NewRGBA64(image.Rect(0, 0, 1, 1))
+			dst.SetRGBA64(0, 0, d)
+
+			CompositeMask(dst, src, nil, opacity, op)
+
+			expected := func(s, d uint16) float64 {
+				c := float64(s)*f[0] + float64(d)*f[1]
+				return c*opacity + float64(d)*(1-opacity)
+			}
+
+			c := dst.RGBA64At(0, 0)
+			for _, v := range [][2]float64{
+				{float64(c.R), expected(s.R, d.R)},
+				{float64(c.G), expected(s.G, d.G)},
+				{float64(c.B), expected(s.B, d.B)},
+				{float64(c.A), expected(s.A, d.A)},
+			} {
+				if math.Abs(v[0]-v[1]) > 1 {
+					t.Errorf("operator %d at opacity %v produced %v, expected %v", op, opacity, v[0], v[1])
+				}
+			}
+		}
+	}
+}
+
+func TestCompositeMaskOver(t *testing.T) {
+	rect := image.Rect(0, 0, 100, 100)
+	src := randomNRGBA64(rect)
+	mask := ConvertToAlpha16(randomNRGBA64(rect))
+	background := randomNRGBA64(rect)
+
+	expected := image.NewRGBA64(rect)
+	Copy(expected, background)
+	draw.DrawMask(expected, rect, src, image.Point{}, mask, image.Point{}, draw.Over)
+
+	dst := image.NewRGBA64(rect)
+	Copy(dst, background)
+	CompositeMask(dst, src, mask, 1, CompositeOver)
+
+	AllPointsRP(func(pt image.Point) {
+		c, e := dst.RGBA64At(pt.X, pt.Y), expected.RGBA64At(pt.X, pt.Y)
+		for _, v := range [][2]uint16{{c.R, e.R}, {c.G, e.G}, {c.B, e.B}, {c.A, e.A}} {
+			if math.Abs(float64(v[0])-float64(v[1])) > 1 {
+				t.Fatalf("expected %v, found %v at %v", e, c, pt)
+			}
+		}
+	})(rect)
+}
@@ -0,0 +1,171 @@
+package imageutil
+
+import (
+	"context"
+	"image"
+	"sync"
+)
+
+// CRP is a context-aware rectangle processor, any function that accepts a
+// context.Context and a single image.Rectangle value as arguments and returns
+// an error. A CRP should stop working and return ctx.Err() once the context is
+// done.
+type CRP func(context.Context, image.Rectangle) error
+
+// noopCRP is a CRP that does nothing but report the state of the context.
+var (
+	noopCRP = func(ctx context.Context, _ image.Rectangle) error {
+		return ctx.Err()
+	}
+)
+
+// ContextRP returns a CRP that calls the given RP on an input rectangle unless
+// the context is already done, in which case ctx.Err() is returned.
+func ContextRP(rp RP) CRP {
+	return func(ctx context.Context, rect image.Rectangle) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rp(rect)
+		return nil
+	}
+}
+
+// PointsCRP returns a CRP that runs a given PP at each point within an input
+// rectangle starting at a given offset and seperated by the given horizontal
+// and vertical stride. The context is checked before each row of points.
+func PointsCRP(offset image.Point, strideH, strideV int, pp PP) CRP {
+	if strideH <= 0 || strideV <= 0 || offset.X < 0 || offset.Y < 0 {
+		return noopCRP
+	}
+
+	return func(ctx context.Context, rect image.Rectangle) error {
+		origin := rect.Min.Add(offset)
+		for y := origin.Y; y < rect.Max.Y; y += strideV {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for x := origin.X; x < rect.Max.X; x += strideH {
+				pp(image.Pt(x, y))
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// AllPointsCRP returns a CRP that runs a given PP at every point within an
+// input rectangle.
+func AllPointsCRP(pp PP) CRP {
+	return PointsCRP(image.Pt(0, 0), 1, 1, pp)
+}
+
+// RowsCRP is the CRP counterpart to RowsRP. No further rows are processed
+// once the given CRP returns an error.
+func RowsCRP(height int, crp CRP) CRP {
+	if height <= 0 {
+		return noopCRP
+	}
+
+	return func(ctx context.Context, rect image.Rectangle) error {
+
+		// Process all but the last row.
+		y := rect.Min.Y
+		for ; y < rect.Max.Y-height; y += height {
+			if err := crp(ctx, image.Rect(rect.Min.X, y, rect.Max.X, y+height)); err != nil {
+				return err
+			}
+		}
+
+		// Process the last row.
+		return crp(ctx, image.Rect(rect.Min.X, y, rect.Max.X, rect.Max.Y))
+	}
+}
+
+// ColumnsCRP is the CRP counterpart to ColumnsRP. No further columns are
+// processed once the given CRP returns an error.
+func ColumnsCRP(width int, crp CRP) CRP {
+	if width <= 0 {
+		return noopCRP
+	}
+
+	return func(ctx context.Context, rect image.Rectangle) error {
+
+		// Process all but the last column.
+		x := rect.Min.X
+		for ; x < rect.Max.X-width; x += width {
+			if err := crp(ctx, image.Rect(x, rect.Min.Y, x+width, rect.Max.Y)); err != nil {
+				return err
+			}
+		}
+
+		// Process the last column.
+		return crp(ctx, image.Rect(x, rect.Min.Y, rect.Max.X, rect.Max.Y))
+	}
+}
+
+// NRowsCRP calls the given CRP on each of n horizontal rectangles that span
+// the input rectangle.
+func NRowsCRP(n int, crp CRP) CRP {
+	if n <= 0 {
+		return noopCRP
+	}
+
+	return func(ctx context.Context, rect image.Rectangle) error {
+		height := (rect.Dy() + n - 1) / n
+		return RowsCRP(height, crp)(ctx, rect)
+	}
+}
+
+// NColumnsCRP calls the given CRP on each of n vertical rectangles that span
+// the input rectangle.
+func NColumnsCRP(n int, crp CRP) CRP {
+	if n <= 0 {
+		return noopCRP
+	}
+
+	return func(ctx context.Context, rect image.Rectangle) error {
+		width := (rect.Dx() + n - 1) / n
+		return ColumnsCRP(width, crp)(ctx, rect)
+	}
+}
+
+// ConcurrentCRP wraps a given CRP in a Go routine and the necessary WaitGroup
+// operations to ensure that completion can be tracked. Once the context is
+// done no further Go routines are started and ctx.Err() is returned instead.
+// Errors returned by the given CRP from within a Go routine are discarded;
+// use a Scheduler to have them surface.
+func ConcurrentCRP(w *sync.WaitGroup, crp CRP) CRP {
+	return func(ctx context.Context, rect image.Rectangle) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		w.Add(1)
+		go func() {
+			crp(ctx, rect)
+			w.Done()
+		}()
+		return nil
+	}
+}
+
+// QuickRowsCRP is the CRP counterpart to QuickRowsRP. It returns once all of
+// the rectangles have been processed or abandoned, returning the first error
+// encountered or ctx.Err() if the context was done.
+func QuickRowsCRP(crp CRP) CRP {
+	return DefaultScheduler.RowsCRP(crp)
+}
+
+// QuickColumnsCRP is the CRP counterpart to QuickColumnsRP. It returns once
+// all of the rectangles have been processed or abandoned, returning the first
+// error encountered or ctx.Err() if the context was done.
+func QuickColumnsCRP(crp CRP) CRP {
+	return DefaultScheduler.ColumnsCRP(crp)
+}
+
+// QuickCRP is the CRP counterpart to QuickRP. It returns once all of the tiles
+// have been processed or abandoned, returning the first error encountered or
+// ctx.Err() if the context was done.
+func QuickCRP(crp CRP) CRP {
+	return DefaultScheduler.CRP(crp)
+}
@@ -0,0 +1,88 @@
+package imageutil
+
+import (
+	"context"
+	"errors"
+	"image"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAllPointsCRP(t *testing.T) {
+	rect := image.Rect(0, 0, 10, 10)
+
+	n := 0
+	if err := AllPointsCRP(func(image.Point) {
+		n++
+	})(context.Background(), rect); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 100 {
+		t.Error("point proccessor ran", n, "times for a 10x10 rectangle")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := AllPointsCRP(func(image.Point) {
+		t.Error("point processor ran with a cancelled context")
+	})(ctx, rect); err != context.Canceled {
+		t.Error("expected context.Canceled, found", err)
+	}
+}
+
+func TestQuickCRPCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var n int64
+	err := QuickCRP(
+		RowsCRP(1, func(ctx context.Context, rect image.Rectangle) error {
+			if atomic.AddInt64(&n, 1) == 10 {
+				cancel()
+			}
+			return ctx.Err()
+		}),
+	)(ctx, image.Rect(0, 0, 10, 1000))
+
+	if err != context.Canceled {
+		t.Error("expected context.Canceled, found", err)
+	}
+
+	if n >= 1000 {
+		t.Error("all rows were processed despite cancellation")
+	}
+}
+
+func TestQuickCRPDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	err := QuickCRP(
+		RowsCRP(1, ContextRP(func(image.Rectangle) {
+			time.Sleep(time.Millisecond)
+		})),
+	)(ctx, image.Rect(0, 0, 10, 1000))
+
+	if err != context.DeadlineExceeded {
+		t.Error("expected context.DeadlineExceeded, found", err)
+	}
+}
+
+func TestQuickCRPError(t *testing.T) {
+	testErr := errors.New("test error")
+
+	err := QuickCRP(
+		RowsCRP(1, func(ctx context.Context, rect image.Rectangle) error {
+			if rect.Min.Y == 500 {
+				return testErr
+			}
+			return ctx.Err()
+		}),
+	)(context.Background(), image.Rect(0, 0, 10, 1000))
+
+	if err != testErr {
+		t.Error("expected the test error, found", err)
+	}
+}
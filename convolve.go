@@ -0,0 +1,248 @@
+package imageutil
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Kernel is a rectangular array of weights for Convolve. The weight at column
+// x and row y is at Weights[y*Width+x], and the kernel is centred on the
+// weight at column Width/2 and row Height/2.
+type Kernel struct {
+	Width, Height int
+	Weights       []float64
+}
+
+// NewKernel returns a Kernel with the given rows of weights, which must all
+// have the same length.
+func NewKernel(rows [][]float64) Kernel {
+	k := Kernel{Height: len(rows)}
+	if len(rows) > 0 {
+		k.Width = len(rows[0])
+	}
+	k.Weights = make([]float64, 0, k.Width*k.Height)
+	for y, row := range rows {
+		if len(row) != k.Width {
+			panic(fmt.Sprintf("imageutil: kernel row %d has %d weights, expected %d", y, len(row), k.Width))
+		}
+		k.Weights = append(k.Weights, row...)
+	}
+	return k
+}
+
+// At returns the weight at the given column and row of the kernel.
+func (k Kernel) At(x, y int) float64 {
+	return k.Weights[y*k.Width+x]
+}
+
+// Sum returns the sum of the kernel's weights.
+func (k Kernel) Sum() float64 {
+	var sum float64
+	for _, w := range k.Weights {
+		sum += w
+	}
+	return sum
+}
+
+// separate returns a row and a column of weights whose product is the kernel
+// and reports whether there are any, which is when the kernel has rank one.
+// Kernels only a single row or column wide are not separated, as applying
+// them directly is no slower.
+func (k Kernel) separate() (row, column []float64, ok bool) {
+	if k.Width < 2 || k.Height < 2 {
+		return nil, nil, false
+	}
+
+	// Divide through by the largest weight, so that the check is relative to
+	// the size of the weights.
+	var pivot float64
+	var px, py int
+	for y := 0; y < k.Height; y++ {
+		for x := 0; x < k.Width; x++ {
+			if w := k.At(x, y); math.Abs(w) > math.Abs(pivot) {
+				pivot, px, py = w, x, y
+			}
+		}
+	}
+	if pivot == 0 {
+		return nil, nil, false
+	}
+
+	row = make([]float64, k.Width)
+	for x := range row {
+		row[x] = k.At(x, py) / pivot
+	}
+	column = make([]float64, k.Height)
+	for y := range column {
+		column[y] = k.At(px, y)
+	}
+
+	tolerance := 1e-9 * math.Abs(pivot)
+	for y := 0; y < k.Height; y++ {
+		for x := 0; x < k.Width; x++ {
+			if math.Abs(column[y]*row[x]-k.At(x, y)) > tolerance {
+				return nil, nil, false
+			}
+		}
+	}
+	return row, column, true
+}
+
+// ConvolveOptions holds the options for Convolve and its variants. The zero
+// value applies the kernel as it is, clamping coordinates outside the bounds.
+type ConvolveOptions struct {
+	// Normalize scales the kernel so that its weights sum to one, unless they
+	// sum to zero. With BorderShrink, the weights within the bounds are
+	// renormalised at each pixel instead.
+	Normalize bool
+
+	// Bias is added to each result, where 1 corresponds to 0xffff.
+	Bias float64
+
+	// Border is how to treat the coordinates outside the bounds.
+	Border Border
+
+	// Alpha is whether Convolve also convolves the alpha component of an
+	// image, rather than leaving it as it is. It should usually be set for
+	// smoothing kernels and left unset for kernels such as edge detectors
+	// whose weights don't sum to one.
+	Alpha bool
+}
+
+// ConvolveFloat concurrently convolves a FloatChannel with a Kernel, returning
+// a *FloatGray with the same bounds whose values are not clamped. As in most
+// image processing libraries, the kernel is not flipped, so the value at (x,
+// y) is the sum of the weights at (i, j) times the values at (x+i-Width/2,
+// y+j-Height/2). Kernels with a rank of one, such as Gaussians, are
+// automatically applied as separate horizontal and vertical passes.
+func ConvolveFloat(img FloatChannel, k Kernel, opts ConvolveOptions) *FloatGray {
+	return convolveFloat(DefaultScheduler, img, k, opts)
+}
+
+// convolveFloat implements ConvolveFloat using the given Scheduler.
+func convolveFloat(s *Scheduler, img FloatChannel, k Kernel, opts ConvolveOptions) *FloatGray {
+	renormalize := false
+	if sum := k.Sum(); opts.Normalize && sum != 0 {
+		if opts.Border == BorderShrink {
+			renormalize = true
+		} else {
+			weights := make([]float64, len(k.Weights))
+			for i, w := range k.Weights {
+				weights[i] = w / sum
+			}
+			k.Weights = weights
+		}
+	}
+
+	if row, column, ok := k.separate(); ok {
+		rows := convolveLine(s, img, row, false, 0, opts.Border, renormalize)
+		return convolveLine(s, rows, column, true, opts.Bias, opts.Border, renormalize)
+	}
+	return convolve2D(s, img, k, opts.Bias, opts.Border, renormalize)
+}
+
+// convolveLine concurrently convolves each row of a FloatChannel, or each
+// column if vertical is set, with a line of weights centred on the middle
+// one, adding bias to the results. If renormalize is set, the results are
+// divided by the sum of the weights used.
+func convolveLine(s *Scheduler, img FloatChannel, weights []float64, vertical bool, bias float64, border Border, renormalize bool) *FloatGray {
+	bounds := img.Bounds()
+	dst := NewFloatGray(bounds)
+	anchor := len(weights) / 2
+
+	convolve := func(i, min, max int, get func(i int) float32) float32 {
+		var sum, weight float64
+		for j, w := range weights {
+			k, ok := border.index(i+j-anchor, min, max)
+			if !ok {
+				continue
+			}
+			weight += w
+			if k >= min && k < max {
+				sum += w * float64(get(k))
+			}
+		}
+		if renormalize && weight != 0 {
+			sum /= weight
+		}
+		return float32(sum + bias)
+	}
+
+	if vertical {
+		s.ColumnsRP(
+			ColumnsRP(1, func(rect image.Rectangle) {
+				x := rect.Min.X
+				get := func(y int) float32 { return img.FloatAt(x, y) }
+				for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+					dst.SetFloat(x, y, convolve(y, bounds.Min.Y, bounds.Max.Y, get))
+				}
+			}),
+		)(bounds)
+	} else {
+		s.RowsRP(
+			RowsRP(1, func(rect image.Rectangle) {
+				y := rect.Min.Y
+				get := func(x int) float32 { return img.FloatAt(x, y) }
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					dst.SetFloat(x, y, convolve(x, bounds.Min.X, bounds.Max.X, get))
+				}
+			}),
+		)(bounds)
+	}
+	return dst
+}
+
+// convolve2D concurrently convolves a FloatChannel with a Kernel directly,
+// adding bias to the results. If renormalize is set, the results are divided
+// by the sum of the weights used.
+func convolve2D(s *Scheduler, img FloatChannel, k Kernel, bias float64, border Border, renormalize bool) *FloatGray {
+	bounds := img.Bounds()
+	dst := NewFloatGray(bounds)
+	ax, ay := k.Width/2, k.Height/2
+
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				var sum, weight float64
+				for j := 0; j < k.Height; j++ {
+					y, ok := border.index(pt.Y+j-ay, bounds.Min.Y, bounds.Max.Y)
+					if !ok {
+						continue
+					}
+					for i := 0; i < k.Width; i++ {
+						x, ok := border.index(pt.X+i-ax, bounds.Min.X, bounds.Max.X)
+						if !ok {
+							continue
+						}
+						w := k.At(i, j)
+						weight += w
+						if (image.Point{X: x, Y: y}.In(bounds)) {
+							sum += w * float64(img.FloatAt(x, y))
+						}
+					}
+				}
+				if renormalize && weight != 0 {
+					sum /= weight
+				}
+				dst.SetFloat(pt.X, pt.Y, float32(sum+bias))
+			},
+		),
+	)(bounds)
+	return dst
+}
+
+// ConvolveGray16 is ConvolveFloat for a Channel, rounding and clamping the
+// result to an *image.Gray16.
+func ConvolveGray16(img Channel, k Kernel, opts ConvolveOptions) *image.Gray16 {
+	return FloatToGray16(ConvolveFloat(ChannelToFloat(img), k, opts))
+}
+
+// Convolve is ConvolveFloat for an ImageReader, convolving each component of
+// its colors premultiplied by alpha, and the alpha component too if
+// opts.Alpha is set, and returning an *image.NRGBA64 with the same bounds.
+func Convolve(img ImageReader, k Kernel, opts ConvolveOptions) *image.NRGBA64 {
+	return filterImage(img, opts.Alpha, func(c FloatChannel) *FloatGray {
+		return ConvolveFloat(c, k, opts)
+	})
+}
@@ -0,0 +1,140 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"testing"
+)
+
+func TestKernelSeparate(t *testing.T) {
+	for name, test := range map[string]struct {
+		k         Kernel
+		separable bool
+	}{
+		"Sobel":     {NewKernel([][]float64{{-1, 0, 1}, {-2, 0, 2}, {-1, 0, 1}}), true},
+		"box":       {NewKernel([][]float64{{1, 1}, {1, 1}, {1, 1}}), true},
+		"Laplacian": {NewKernel([][]float64{{0, 1, 0}, {1, -4, 1}, {0, 1, 0}}), false},
+		"row":       {NewKernel([][]float64{{1, 2, 1}}), false},
+		"zero":      {NewKernel([][]float64{{0, 0}, {0, 0}}), false},
+	} {
+		row, column, ok := test.k.separate()
+		if ok != test.separable {
+			t.Errorf("%s kernel separable %v, expected %v", name, ok, test.separable)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for y := range column {
+			for x := range row {
+				if v := column[y] * row[x]; math.Abs(v-test.k.At(x, y)) > 1e-12 {
+					t.Errorf("%s kernel separated into %v and %v", name, row, column)
+				}
+			}
+		}
+	}
+}
+
+func TestConvolveFloat(t *testing.T) {
+	img := ChannelToFloat(ConvertToGray16(randomNRGBA64(copyTestRect)))
+	g := gaussianKernel(1.5)
+	rows := make([][]float64, len(g))
+	for y := range rows {
+		rows[y] = make([]float64, len(g))
+		for x := range rows[y] {
+			rows[y][x] = g[x] * g[y]
+		}
+	}
+	k := NewKernel(rows)
+
+	// The separated passes should match the kernel applied directly.
+	sum := k.Sum()
+	scaled := Kernel{Width: k.Width, Height: k.Height, Weights: make([]float64, len(k.Weights))}
+	for i, w := range k.Weights {
+		scaled.Weights[i] = w / sum
+	}
+	for _, border := range []Border{BorderClamp, BorderMirror, BorderWrap, BorderZero, BorderShrink} {
+		opts := ConvolveOptions{Normalize: true, Bias: 0.1, Border: border}
+		separated := ConvolveFloat(img, k, opts)
+		direct := convolve2D(DefaultScheduler, img, scaled, 0.1, border, border == BorderShrink)
+		if separated.Bounds() != img.Bounds() {
+			t.Errorf("convolution has bounds %v, expected %v", separated.Bounds(), img.Bounds())
+		}
+		AllPointsRP(
+			func(pt image.Point) {
+				if a, b := separated.FloatAt(pt.X, pt.Y), direct.FloatAt(pt.X, pt.Y); math.Abs(float64(a-b)) > 1e-5 {
+					t.Errorf("separated convolution with border %d is %v at %v, expected %v", border, a, pt, b)
+				}
+			},
+		)(img.Rect)
+	}
+
+	// A normalised box kernel with BorderShrink is a box blur.
+	box := NewKernel([][]float64{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}})
+	convolved := ConvolveFloat(img, box, ConvolveOptions{Normalize: true, Border: BorderShrink})
+	blurred := BoxBlurFloat(1, img)
+	AllPointsRP(
+		func(pt image.Point) {
+			if a, b := convolved.FloatAt(pt.X, pt.Y), blurred.FloatAt(pt.X, pt.Y); math.Abs(float64(a-b)) > 1e-5 {
+				t.Errorf("box convolution is %v at %v, expected %v", a, pt, b)
+			}
+		},
+	)(img.Rect)
+
+	// The kernel isn't flipped, and its weights are used as they are.
+	f := NewFloatGray(image.Rect(0, 0, 4, 1))
+	copy(f.Pix, []float32{1, 2, 3, 4})
+	shifted := ConvolveFloat(f, NewKernel([][]float64{{0, 0, 2}}), ConvolveOptions{Border: BorderWrap})
+	for x, expected := range []float32{4, 6, 8, 2} {
+		if v := shifted.FloatAt(x, 0); v != expected {
+			t.Errorf("shifted value at %d is %v, expected %v", x, v, expected)
+		}
+	}
+}
+
+func TestConvolve(t *testing.T) {
+	src := randomNRGBA64(copyTestRect)
+
+	gray := ConvertToGray16(src)
+	if dst := ConvolveGray16(gray, NewKernel([][]float64{{1}}), ConvolveOptions{}); !bytes.Equal(dst.Pix, gray.Pix) {
+		t.Error("convolving with an identity kernel changed the channel")
+	}
+
+	// Smoothing kernels should convolve alpha too.
+	box := NewKernel([][]float64{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}})
+	convolved := Convolve(src, box, ConvolveOptions{Normalize: true, Border: BorderShrink, Alpha: true})
+	blurred := BoxBlur(1, src)
+	AllPointsRP(
+		func(pt image.Point) {
+			a, b := convolved.NRGBA64At(pt.X, pt.Y), blurred.NRGBA64At(pt.X, pt.Y)
+			if d := int(a.A) - int(b.A); d < -1 || d > 1 {
+				t.Errorf("box convolution has alpha %#04x at %v, expected %#04x", a.A, pt, b.A)
+			}
+		},
+	)(copyTestRect)
+
+	// Edge detectors shouldn't make an opaque image transparent.
+	opaque := image.NewNRGBA64(copyTestRect)
+	copy(opaque.Pix, src.(*image.NRGBA64).Pix)
+	for i := 6; i < len(opaque.Pix); i += 8 {
+		opaque.Pix[i], opaque.Pix[i+1] = 0xff, 0xff
+	}
+	laplacian := NewKernel([][]float64{{0, 1, 0}, {1, -4, 1}, {0, 1, 0}})
+	edges := Convolve(opaque, laplacian, ConvolveOptions{Bias: 0.5})
+	AllPointsRP(
+		func(pt image.Point) {
+			if a := edges.NRGBA64At(pt.X, pt.Y).A; a != math.MaxUint16 {
+				t.Errorf("Laplacian has alpha %#04x at %v", a, pt)
+			}
+		},
+	)(copyTestRect)
+}
+
+func BenchmarkConvolveFloat(b *testing.B) {
+	img := ChannelToFloat(ConvertToGray16(randomNRGBA64(image.Rect(0, 0, 256, 256))))
+	k := NewKernel([][]float64{{0, 1, 0}, {1, -4, 1}, {0, 1, 0}})
+	for i := 0; i < b.N; i++ {
+		ConvolveFloat(img, k, ConvolveOptions{})
+	}
+}
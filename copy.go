@@ -0,0 +1,316 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+)
+
+// pixCopier copies pixels from a source image, starting at sp, into the
+// rectangle r of a destination image by operating directly on their Pix
+// slices. Both rectangles must lie within the bounds of their images.
+type pixCopier func(r image.Rectangle, sp image.Point)
+
+// fastCopier returns a pixCopier from src to dst if their concrete types are
+// a known pair, and nil otherwise. The colors copied are identical to those
+// that dst.Set(x, y, src.At(x, y)) would produce.
+func fastCopier(dst ImageReadWriter, src ImageReader) pixCopier {
+	switch d := dst.(type) {
+	case *image.RGBA:
+		switch s := src.(type) {
+		case *image.RGBA:
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 4)
+		case *image.NRGBA:
+			return nrgbaToRGBACopier(d, s)
+		case *image.Gray:
+			return grayTo4Copier(d.Pix, d.PixOffset, s)
+		case *image.YCbCr:
+			return ycbcrTo4Copier(d.Pix, d.PixOffset, s)
+		case *YCbCr:
+			return ycbcrTo4Copier(d.Pix, d.PixOffset, s.YCbCr)
+		case *image.Paletted:
+			return palettedTo4Copier(d.Pix, d.PixOffset, s, color.RGBAModel)
+		case *Planar:
+			return fromPlanarCopier(d.Pix, d.PixOffset, 4, s, setRGBAPix)
+		}
+	case *image.NRGBA:
+		switch s := src.(type) {
+		case *image.NRGBA:
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 4)
+		case *image.RGBA:
+			return rgbaToNRGBACopier(d, s)
+		case *image.Gray:
+			return grayTo4Copier(d.Pix, d.PixOffset, s)
+		case *image.YCbCr:
+			return ycbcrTo4Copier(d.Pix, d.PixOffset, s)
+		case *YCbCr:
+			return ycbcrTo4Copier(d.Pix, d.PixOffset, s.YCbCr)
+		case *image.Paletted:
+			return palettedTo4Copier(d.Pix, d.PixOffset, s, color.NRGBAModel)
+		case *Planar:
+			return fromPlanarCopier(d.Pix, d.PixOffset, 4, s, setNRGBAPix)
+		}
+	case *image.RGBA64:
+		switch s := src.(type) {
+		case *image.RGBA64:
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 8)
+		case *Planar:
+			return fromPlanarCopier(d.Pix, d.PixOffset, 8, s, setRGBA64Pix)
+		}
+	case *image.NRGBA64:
+		switch s := src.(type) {
+		case *image.NRGBA64:
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 8)
+		case *Planar:
+			return fromPlanarCopier(d.Pix, d.PixOffset, 8, s, setNRGBA64Pix)
+		}
+	case *image.Alpha:
+		if s, ok := src.(*image.Alpha); ok {
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 1)
+		}
+	case *image.Alpha16:
+		if s, ok := src.(*image.Alpha16); ok {
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 2)
+		}
+	case *image.Gray:
+		if s, ok := src.(*image.Gray); ok {
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 1)
+		}
+	case *image.Gray16:
+		if s, ok := src.(*image.Gray16); ok {
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 2)
+		}
+	case *image.CMYK:
+		if s, ok := src.(*image.CMYK); ok {
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 4)
+		}
+	case *image.Paletted:
+		if s, ok := src.(*image.Paletted); ok && samePalette(d.Palette, s.Palette) {
+			return samePixCopier(d.Pix, d.Stride, d.PixOffset, s.Pix, s.Stride, s.PixOffset, 1)
+		}
+	case *Planar:
+		return toPlanarCopier(d, src)
+	}
+
+	return nil
+}
+
+// samePixCopier returns a pixCopier between two images that share a pixel
+// layout of bpp bytes per pixel.
+func samePixCopier(dPix []uint8, dStride int, dOffset func(x, y int) int, sPix []uint8, sStride int, sOffset func(x, y int) int, bpp int) pixCopier {
+	return func(r image.Rectangle, sp image.Point) {
+		if r.Empty() {
+			return
+		}
+
+		n := r.Dx() * bpp
+		di, si := dOffset(r.Min.X, r.Min.Y), sOffset(sp.X, sp.Y)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			copy(dPix[di:di+n], sPix[si:si+n])
+			di += dStride
+			si += sStride
+		}
+	}
+}
+
+// pixLayout returns the Pix slice, stride and PixOffset method of an image
+// that stores its pixels in a single Pix slice, and a nil slice otherwise.
+func pixLayout(img image.Image) ([]uint8, int, func(x, y int) int) {
+	switch i := img.(type) {
+	case *image.RGBA:
+		return i.Pix, i.Stride, i.PixOffset
+	case *image.NRGBA:
+		return i.Pix, i.Stride, i.PixOffset
+	case *image.RGBA64:
+		return i.Pix, i.Stride, i.PixOffset
+	case *image.NRGBA64:
+		return i.Pix, i.Stride, i.PixOffset
+	case *image.Alpha:
+		return i.Pix, i.Stride, i.PixOffset
+	case *image.Alpha16:
+		return i.Pix, i.Stride, i.PixOffset
+	case *image.Gray:
+		return i.Pix, i.Stride, i.PixOffset
+	case *image.Gray16:
+		return i.Pix, i.Stride, i.PixOffset
+	case *image.CMYK:
+		return i.Pix, i.Stride, i.PixOffset
+	case *image.Paletted:
+		return i.Pix, i.Stride, i.PixOffset
+	}
+	return nil, 0, nil
+}
+
+// samePixels reports whether two images are the same or, like an image and a
+// SubImage of it, have the same type and store each point in the same bytes
+// of a shared Pix array.
+func samePixels(dst ImageReadWriter, src ImageReader) bool {
+	if dst == src {
+		return true
+	}
+	if reflect.TypeOf(dst) != reflect.TypeOf(src) {
+		return false
+	}
+
+	dPix, dStride, dOffset := pixLayout(dst)
+	sPix, sStride, sOffset := pixLayout(src)
+	if cap(dPix) == 0 || cap(sPix) == 0 || &dPix[:cap(dPix)][cap(dPix)-1] != &sPix[:cap(sPix)][cap(sPix)-1] {
+		return false
+	}
+
+	// Both slices end with the array, so their offsets from its end address
+	// the same bytes.
+	return dStride == sStride && dOffset(0, 0)-cap(dPix) == sOffset(0, 0)-cap(sPix)
+}
+
+// samePalette reports whether two palettes hold the same colors in the same
+// order.
+func samePalette(a, b color.Palette) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// nrgbaToRGBACopier returns a pixCopier that premultiplies the pixels of an
+// *image.NRGBA into an *image.RGBA.
+func nrgbaToRGBACopier(d *image.RGBA, s *image.NRGBA) pixCopier {
+	return func(r image.Rectangle, sp image.Point) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			di := d.PixOffset(r.Min.X, y)
+			si := s.PixOffset(sp.X, sp.Y+y-r.Min.Y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				cr, cg, cb, ca := color.NRGBA{
+					R: s.Pix[si+0],
+					G: s.Pix[si+1],
+					B: s.Pix[si+2],
+					A: s.Pix[si+3],
+				}.RGBA()
+				d.Pix[di+0] = uint8(cr >> 8)
+				d.Pix[di+1] = uint8(cg >> 8)
+				d.Pix[di+2] = uint8(cb >> 8)
+				d.Pix[di+3] = uint8(ca >> 8)
+				di += 4
+				si += 4
+			}
+		}
+	}
+}
+
+// rgbaToNRGBACopier returns a pixCopier that unpremultiplies the pixels of an
+// *image.RGBA into an *image.NRGBA, as color.NRGBAModel does.
+func rgbaToNRGBACopier(d *image.NRGBA, s *image.RGBA) pixCopier {
+	return func(r image.Rectangle, sp image.Point) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			di := d.PixOffset(r.Min.X, y)
+			si := s.PixOffset(sp.X, sp.Y+y-r.Min.Y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				cr, cg, cb, ca := color.RGBA{
+					R: s.Pix[si+0],
+					G: s.Pix[si+1],
+					B: s.Pix[si+2],
+					A: s.Pix[si+3],
+				}.RGBA()
+
+				switch ca {
+				case 0xffff:
+				case 0:
+					cr, cg, cb = 0, 0, 0
+				default:
+					cr = (cr * 0xffff) / ca
+					cg = (cg * 0xffff) / ca
+					cb = (cb * 0xffff) / ca
+				}
+
+				d.Pix[di+0] = uint8(cr >> 8)
+				d.Pix[di+1] = uint8(cg >> 8)
+				d.Pix[di+2] = uint8(cb >> 8)
+				d.Pix[di+3] = uint8(ca >> 8)
+				di += 4
+				si += 4
+			}
+		}
+	}
+}
+
+// grayTo4Copier returns a pixCopier from an *image.Gray into an opaque image
+// with four bytes per pixel, such as an *image.RGBA or *image.NRGBA.
+func grayTo4Copier(dPix []uint8, dOffset func(x, y int) int, s *image.Gray) pixCopier {
+	return func(r image.Rectangle, sp image.Point) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			di := dOffset(r.Min.X, y)
+			si := s.PixOffset(sp.X, sp.Y+y-r.Min.Y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				g := s.Pix[si]
+				dPix[di+0] = g
+				dPix[di+1] = g
+				dPix[di+2] = g
+				dPix[di+3] = 0xff
+				di += 4
+				si++
+			}
+		}
+	}
+}
+
+// ycbcrTo4Copier returns a pixCopier from an *image.YCbCr into an opaque
+// image with four bytes per pixel, such as an *image.RGBA or *image.NRGBA.
+func ycbcrTo4Copier(dPix []uint8, dOffset func(x, y int) int, s *image.YCbCr) pixCopier {
+	return func(r image.Rectangle, sp image.Point) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			di := dOffset(r.Min.X, y)
+			sy := sp.Y + y - r.Min.Y
+			for x := r.Min.X; x < r.Max.X; x++ {
+				sx := sp.X + x - r.Min.X
+				ci := s.COffset(sx, sy)
+				cr, cg, cb, _ := color.YCbCr{
+					Y:  s.Y[s.YOffset(sx, sy)],
+					Cb: s.Cb[ci],
+					Cr: s.Cr[ci],
+				}.RGBA()
+				dPix[di+0] = uint8(cr >> 8)
+				dPix[di+1] = uint8(cg >> 8)
+				dPix[di+2] = uint8(cb >> 8)
+				dPix[di+3] = 0xff
+				di += 4
+			}
+		}
+	}
+}
+
+// palettedTo4Copier returns a pixCopier from an *image.Paletted into an
+// image with four bytes per pixel, looking up each index in a table built by
+// converting the palette using the given model, which must produce either
+// color.RGBA or color.NRGBA values.
+func palettedTo4Copier(dPix []uint8, dOffset func(x, y int) int, s *image.Paletted, model color.Model) pixCopier {
+	var table [256][4]uint8
+	for i, c := range s.Palette {
+		if i >= len(table) {
+			break
+		}
+
+		switch c := model.Convert(c).(type) {
+		case color.RGBA:
+			table[i] = [4]uint8{c.R, c.G, c.B, c.A}
+		case color.NRGBA:
+			table[i] = [4]uint8{c.R, c.G, c.B, c.A}
+		}
+	}
+
+	return func(r image.Rectangle, sp image.Point) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			di := dOffset(r.Min.X, y)
+			si := s.PixOffset(sp.X, sp.Y+y-r.Min.Y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				copy(dPix[di:di+4], table[s.Pix[si]][:])
+				di += 4
+				si++
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+var (
+	copyTestRect = image.Rect(-3, 5, 97, 83)
+)
+
+// opaqueImage hides the concrete type of an image, forcing Copy to use its
+// generic path.
+type opaqueImage struct {
+	image.Image
+}
+
+func randomYCbCr(rect image.Rectangle, ratio image.YCbCrSubsampleRatio) *image.YCbCr {
+	img := image.NewYCbCr(rect, ratio)
+	rand.Read(img.Y)
+	rand.Read(img.Cb)
+	rand.Read(img.Cr)
+	return img
+}
+
+func randomPaletted(rect image.Rectangle, p color.Palette) *image.Paletted {
+	img := image.NewPaletted(rect, p)
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rand.Intn(len(p)))
+	}
+	return img
+}
+
+func copyTestSources() map[string]image.Image {
+	src := randomNRGBA64(copyTestRect)
+	cmyk := image.NewCMYK(copyTestRect)
+	Copy(cmyk, src)
+
+	return map[string]image.Image{
+		"RGBA":          ConvertToRGBA(src),
+		"NRGBA":         ConvertToNRGBA(src),
+		"RGBA64":        ConvertToRGBA64(src),
+		"NRGBA64":       src,
+		"Alpha":         ConvertToAlpha(src),
+		"Alpha16":       ConvertToAlpha16(src),
+		"Gray":          ConvertToGray(src),
+		"Gray16":        ConvertToGray16(src),
+		"CMYK":          cmyk,
+		"YCbCr444":      randomYCbCr(copyTestRect, image.YCbCrSubsampleRatio444),
+		"YCbCr420":      randomYCbCr(copyTestRect, image.YCbCrSubsampleRatio420),
+		"YCbCr411":      randomYCbCr(copyTestRect, image.YCbCrSubsampleRatio411),
+		"Paletted":      randomPaletted(copyTestRect, palette.Plan9),
+		"PalettedShort": randomPaletted(copyTestRect, palette.Plan9[:16]),
+	}
+}
+
+func copyTestDestinations() map[string]func() ImageReadWriter {
+	return map[string]func() ImageReadWriter{
+		"RGBA":     func() ImageReadWriter { return image.NewRGBA(copyTestRect) },
+		"NRGBA":    func() ImageReadWriter { return image.NewNRGBA(copyTestRect) },
+		"RGBA64":   func() ImageReadWriter { return image.NewRGBA64(copyTestRect) },
+		"NRGBA64":  func() ImageReadWriter { return image.NewNRGBA64(copyTestRect) },
+		"Alpha":    func() ImageReadWriter { return image.NewAlpha(copyTestRect) },
+		"Alpha16":  func() ImageReadWriter { return image.NewAlpha16(copyTestRect) },
+		"Gray":     func() ImageReadWriter { return image.NewGray(copyTestRect) },
+		"Gray16":   func() ImageReadWriter { return image.NewGray16(copyTestRect) },
+		"CMYK":     func() ImageReadWriter { return image.NewCMYK(copyTestRect) },
+		"Paletted": func() ImageReadWriter { return image.NewPaletted(copyTestRect, palette.Plan9) },
+	}
+}
+
+// pix returns the Pix slice of one of the standard image types.
+func pix(img ImageReader) []uint8 {
+	switch img := img.(type) {
+	case *image.RGBA:
+		return img.Pix
+	case *image.NRGBA:
+		return img.Pix
+	case *image.RGBA64:
+		return img.Pix
+	case *image.NRGBA64:
+		return img.Pix
+	case *image.Alpha:
+		return img.Pix
+	case *image.Alpha16:
+		return img.Pix
+	case *image.Gray:
+		return img.Pix
+	case *image.Gray16:
+		return img.Pix
+	case *image.CMYK:
+		return img.Pix
+	case *image.Paletted:
+		return img.Pix
+	}
+	return nil
+}
+
+func TestCopyFast(t *testing.T) {
+	for srcName, src := range copyTestSources() {
+		for dstName, newDst := range copyTestDestinations() {
+			expected := newDst()
+			Copy(expected, opaqueImage{src})
+
+			dst := newDst()
+			Copy(dst, src)
+
+			if !bytes.Equal(pix(dst), pix(expected)) {
+				t.Errorf("copying %s to %s differed from the generic copy", srcName, dstName)
+			}
+		}
+	}
+}
+
+func TestCopyFastSubImage(t *testing.T) {
+	rect := image.Rect(10, 20, 50, 60)
+	src := randomYCbCr(copyTestRect, image.YCbCrSubsampleRatio420).SubImage(rect)
+
+	expected := image.NewRGBA(rect)
+	Copy(expected, opaqueImage{src})
+
+	dst := image.NewRGBA(rect)
+	Copy(dst, src)
+
+	if !bytes.Equal(dst.Pix, expected.Pix) {
+		t.Error("copying a YCbCr sub-image differed from the generic copy")
+	}
+}
+
+func TestCopyRect(t *testing.T) {
+	fill := image.NewUniform(color.RGBA{1, 2, 3, 4})
+	for srcName, src := range copyTestSources() {
+		for dstName, newDst := range copyTestDestinations() {
+			for _, tc := range []struct {
+				dstPt   image.Point
+				srcRect image.Rectangle
+			}{
+				{image.Pt(0, 10), image.Rect(20, 30, 60, 50)},
+				{image.Pt(-10, -10), image.Rect(-20, 0, 40, 100)},
+				{image.Pt(90, 80), image.Rect(0, 10, 50, 50)},
+				{image.Pt(1000, 1000), image.Rect(0, 10, 50, 50)},
+			} {
+				expected := newDst()
+				draw.Draw(expected, expected.Bounds(), fill, image.Point{}, draw.Src)
+				draw.Draw(expected, image.Rectangle{Min: tc.dstPt, Max: tc.dstPt.Add(tc.srcRect.Size())}, opaqueImage{src}, tc.srcRect.Min, draw.Src)
+
+				dst := newDst()
+				draw.Draw(dst, dst.Bounds(), fill, image.Point{}, draw.Src)
+				CopyRect(dst, tc.dstPt, src, tc.srcRect)
+
+				if !bytes.Equal(pix(dst), pix(expected)) {
+					t.Errorf("copying %v of %s to %v of %s differed from draw.Draw", tc.srcRect, srcName, tc.dstPt, dstName)
+				}
+			}
+		}
+	}
+}
+
+func TestCopyRectOverlapping(t *testing.T) {
+	src := ConvertToRGBA(randomNRGBA64(copyTestRect))
+	srcRect := image.Rect(10, 20, 60, 70)
+
+	for _, delta := range []image.Point{
+		image.Pt(1, 0), image.Pt(-1, 0), image.Pt(0, 1), image.Pt(0, -1),
+		image.Pt(7, 5), image.Pt(-7, 5), image.Pt(7, -5), image.Pt(-7, -5),
+	} {
+		expected := image.NewRGBA(copyTestRect)
+		Copy(expected, src)
+		CopyRect(expected, srcRect.Min.Add(delta), src, srcRect)
+
+		img := image.NewRGBA(copyTestRect)
+		Copy(img, src)
+		CopyRect(img, srcRect.Min.Add(delta), img, srcRect)
+
+		if !bytes.Equal(img.Pix, expected.Pix) {
+			t.Errorf("overlapping copy by %v differed from a buffered copy", delta)
+		}
+
+		// Hide the concrete type to force the generic path.
+		generic := struct{ ImageReadWriter }{image.NewRGBA(copyTestRect)}
+		Copy(generic, src)
+		CopyRect(generic, srcRect.Min.Add(delta), generic, srcRect)
+
+		if !bytes.Equal(pix(generic.ImageReadWriter), expected.Pix) {
+			t.Errorf("generic overlapping copy by %v differed from a buffered copy", delta)
+		}
+	}
+}
+
+func TestCopyRectSubImage(t *testing.T) {
+	src := randomNRGBA64(copyTestRect).(*image.NRGBA64)
+	srcRect := image.Rect(10, 20, 60, 70)
+
+	for _, delta := range []image.Point{
+		image.Pt(1, 0), image.Pt(-1, 0), image.Pt(0, 1), image.Pt(0, -1),
+		image.Pt(7, 5), image.Pt(-7, 5), image.Pt(7, -5), image.Pt(-7, -5),
+	} {
+		for name, newImage := range map[string]func(image.Rectangle) ImageReadWriter{
+			"RGBA":    func(r image.Rectangle) ImageReadWriter { return image.NewRGBA(r) },
+			"NRGBA64": func(r image.Rectangle) ImageReadWriter { return image.NewNRGBA64(r) },
+			"Gray":    func(r image.Rectangle) ImageReadWriter { return image.NewGray(r) },
+		} {
+			img, clone := newImage(copyTestRect), newImage(copyTestRect)
+			Copy(img, src)
+			Copy(clone, src)
+			expected := newImage(copyTestRect)
+			Copy(expected, src)
+			CopyRect(expected, srcRect.Min.Add(delta), clone, srcRect)
+
+			// A SubImage shares the destination's Pix slice.
+			sub := img.(interface {
+				SubImage(image.Rectangle) image.Image
+			}).SubImage(srcRect).(ImageReader)
+			CopyRect(img, srcRect.Min.Add(delta), sub, srcRect)
+
+			if !bytes.Equal(pix(img), pix(expected)) {
+				t.Errorf("%s copy of a SubImage by %v differed from a copy of a clone", name, delta)
+			}
+		}
+	}
+}
+
+func benchmarkCopy(b *testing.B, dst ImageReadWriter, src ImageReader) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Copy(dst, src)
+	}
+}
+
+func BenchmarkCopyRGBA(b *testing.B) {
+	src := ConvertToRGBA(randomNRGBA64(imageutilTestRect))
+	benchmarkCopy(b, image.NewRGBA(imageutilTestRect), src)
+}
+
+func BenchmarkCopyRGBAGeneric(b *testing.B) {
+	src := ConvertToRGBA(randomNRGBA64(imageutilTestRect))
+	benchmarkCopy(b, image.NewRGBA(imageutilTestRect), opaqueImage{src})
+}
+
+func BenchmarkCopyNRGBAToRGBA(b *testing.B) {
+	src := ConvertToNRGBA(randomNRGBA64(imageutilTestRect))
+	benchmarkCopy(b, image.NewRGBA(imageutilTestRect), src)
+}
+
+func BenchmarkCopyNRGBAToRGBAGeneric(b *testing.B) {
+	src := ConvertToNRGBA(randomNRGBA64(imageutilTestRect))
+	benchmarkCopy(b, image.NewRGBA(imageutilTestRect), opaqueImage{src})
+}
+
+func BenchmarkCopyYCbCrToRGBA(b *testing.B) {
+	src := randomYCbCr(imageutilTestRect, image.YCbCrSubsampleRatio420)
+	benchmarkCopy(b, image.NewRGBA(imageutilTestRect), src)
+}
+
+func BenchmarkCopyYCbCrToRGBAGeneric(b *testing.B) {
+	src := randomYCbCr(imageutilTestRect, image.YCbCrSubsampleRatio420)
+	benchmarkCopy(b, image.NewRGBA(imageutilTestRect), opaqueImage{src})
+}
+
+func BenchmarkCopyPalettedToRGBA(b *testing.B) {
+	src := randomPaletted(imageutilTestRect, palette.Plan9)
+	benchmarkCopy(b, image.NewRGBA(imageutilTestRect), src)
+}
+
+func BenchmarkCopyPalettedToRGBAGeneric(b *testing.B) {
+	src := randomPaletted(imageutilTestRect, palette.Plan9)
+	benchmarkCopy(b, image.NewRGBA(imageutilTestRect), opaqueImage{src})
+}
@@ -0,0 +1,289 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// The smoothing and differencing weights of the gradient operators, whose
+// horizontal kernels are the products of a column of smoothing weights and a
+// row of differencing weights, and whose vertical kernels are their
+// transposes.
+var (
+	sobelWeights   = []float64{1, 2, 1}
+	scharrWeights  = []float64{3, 10, 3}
+	prewittWeights = []float64{1, 1, 1}
+	centralWeights = []float64{-1, 0, 1}
+)
+
+// gradientKernels returns the horizontal and vertical kernels of a gradient
+// operator with the given smoothing weights, scaled so that a step from 0 to
+// 1 has a gradient of 1.
+func gradientKernels(smooth []float64) (kx, ky Kernel) {
+	var sum float64
+	for _, w := range smooth {
+		sum += w
+	}
+
+	n := len(smooth)
+	kx = Kernel{Width: len(centralWeights), Height: n, Weights: make([]float64, 0, n*len(centralWeights))}
+	ky = Kernel{Width: n, Height: len(centralWeights), Weights: make([]float64, 0, n*len(centralWeights))}
+	for _, s := range smooth {
+		for _, d := range centralWeights {
+			kx.Weights = append(kx.Weights, s*d/sum)
+		}
+	}
+	for _, d := range centralWeights {
+		for _, s := range smooth {
+			ky.Weights = append(ky.Weights, s*d/sum)
+		}
+	}
+	return kx, ky
+}
+
+// gradientFloat concurrently returns the horizontal and vertical gradients of
+// a FloatChannel using the gradient operator with the given smoothing
+// weights, mirroring the values at the edges.
+func gradientFloat(s *Scheduler, img FloatChannel, smooth []float64) (gx, gy *FloatGray) {
+	kx, ky := gradientKernels(smooth)
+	opts := ConvolveOptions{Border: BorderMirror}
+	return convolveFloat(s, img, kx, opts), convolveFloat(s, img, ky, opts)
+}
+
+// orientation returns the direction of a gradient as a fraction of a turn,
+// from 0 for a gradient increasing in x to 0x4000 for one increasing in y,
+// wrapping around to 0 at a full turn.
+func orientation(gx, gy float64) uint16 {
+	theta := math.Atan2(gy, gx)
+	if theta < 0 {
+		theta += 2 * math.Pi
+	}
+	return uint16(int(math.Round(theta/(2*math.Pi)*0x10000)) % 0x10000)
+}
+
+// gradientGray16 concurrently returns the magnitude and orientation of the
+// gradients of a Channel using the gradient operator with the given
+// smoothing weights.
+func gradientGray16(s *Scheduler, img Channel, smooth []float64) (magnitude, orient *image.Gray16) {
+	gx, gy := gradientFloat(s, ChannelToFloat(img), smooth)
+
+	bounds := img.Bounds()
+	magnitude, orient = image.NewGray16(bounds), image.NewGray16(bounds)
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				x, y := float64(gx.FloatAt(pt.X, pt.Y)), float64(gy.FloatAt(pt.X, pt.Y))
+				magnitude.SetGray16(pt.X, pt.Y, color.Gray16{Y: clampUint16(math.Hypot(x, y) * math.MaxUint16)})
+				orient.SetGray16(pt.X, pt.Y, color.Gray16{Y: orientation(x, y)})
+			},
+		),
+	)(bounds)
+	return magnitude, orient
+}
+
+// SobelGray16 concurrently applies the Sobel operator to a Channel, returning
+// the magnitudes and orientations of its gradients as *image.Gray16 images
+// with the same bounds. Magnitudes are scaled so that a horizontal or
+// vertical step from 0 to 0xffff has a magnitude of 0xffff, and stronger
+// diagonal responses are clamped. Orientations are fractions of a turn, from
+// 0 for a gradient increasing in x through 0x4000 for one increasing in y,
+// and are 0 where there is no gradient. The values are mirrored at the edges.
+func SobelGray16(img Channel) (magnitude, orientation *image.Gray16) {
+	return gradientGray16(DefaultScheduler, img, sobelWeights)
+}
+
+// ScharrGray16 is SobelGray16 with the Scharr operator, whose orientations
+// are more accurate.
+func ScharrGray16(img Channel) (magnitude, orientation *image.Gray16) {
+	return gradientGray16(DefaultScheduler, img, scharrWeights)
+}
+
+// PrewittGray16 is SobelGray16 with the Prewitt operator.
+func PrewittGray16(img Channel) (magnitude, orientation *image.Gray16) {
+	return gradientGray16(DefaultScheduler, img, prewittWeights)
+}
+
+// LaplacianOfGaussianGray16 concurrently applies the Laplacian of a Gaussian
+// with the given standard deviation to a Channel, returning an *image.Gray16
+// with the same bounds. The response is multiplied by the variance, so that
+// it is comparable between standard deviations, and offset so that zero is
+// 0x8000. Edges lie at its zero crossings, with the darker side above 0x8000.
+func LaplacianOfGaussianGray16(sigma float64, img Channel) *image.Gray16 {
+	return laplacianOfGaussianGray16(DefaultScheduler, sigma, img)
+}
+
+// laplacianOfGaussianGray16 implements LaplacianOfGaussianGray16 using the
+// given Scheduler.
+func laplacianOfGaussianGray16(s *Scheduler, sigma float64, img Channel) *image.Gray16 {
+	if sigma <= 0 {
+		dst := image.NewGray16(img.Bounds())
+		s.RP(
+			AllPointsRP(
+				func(pt image.Point) {
+					dst.SetGray16(pt.X, pt.Y, color.Gray16{Y: 0x8000})
+				},
+			),
+		)(dst.Rect)
+		return dst
+	}
+
+	// The Laplacian of a Gaussian is the sum of its second derivatives in x
+	// and y, each of which is separable into a second derivative of a
+	// one-dimensional Gaussian and a one-dimensional Gaussian.
+	g := gaussianKernel(sigma)
+	radius := len(g) / 2
+	var sum float64
+	for _, w := range g {
+		sum += w
+	}
+	d2 := make([]float64, len(g))
+	var d2Sum float64
+	for i := range g {
+		g[i] /= sum
+		x := float64(i - radius)
+		d2[i] = (x*x - sigma*sigma) / (sigma * sigma) * g[i]
+		d2Sum += d2[i]
+	}
+
+	// Make the second derivative sum to zero, so that uniform regions have no
+	// response despite the truncation.
+	for i := range d2 {
+		d2[i] -= d2Sum * g[i]
+	}
+
+	n := len(g)
+	kxx := Kernel{Width: n, Height: n, Weights: make([]float64, n*n)}
+	kyy := Kernel{Width: n, Height: n, Weights: make([]float64, n*n)}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			kxx.Weights[y*n+x] = g[y] * d2[x]
+			kyy.Weights[y*n+x] = d2[y] * g[x]
+		}
+	}
+	opts := ConvolveOptions{Border: BorderMirror}
+	f := ChannelToFloat(img)
+	gxx, gyy := convolveFloat(s, f, kxx, opts), convolveFloat(s, f, kyy, opts)
+
+	dst := image.NewGray16(img.Bounds())
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				v := float64(gxx.FloatAt(pt.X, pt.Y)+gyy.FloatAt(pt.X, pt.Y)) + 0.5
+				dst.SetGray16(pt.X, pt.Y, color.Gray16{Y: clampUint16(v * math.MaxUint16)})
+			},
+		),
+	)(dst.Rect)
+	return dst
+}
+
+// CannyGray16 applies the Canny edge detector to a Channel, returning an
+// *image.Gray16 with the same bounds that is 0xffff at edges and 0 elsewhere.
+// The channel is smoothed with a Gaussian with the given standard deviation,
+// unless it is zero, and its Sobel gradients are thinned to their local
+// maxima along the gradient direction. Maxima with magnitudes of at least
+// high are edges, as are those of at least low that are connected to them
+// through other such maxima. Magnitudes are scaled as in SobelGray16, so that
+// a step from 0 to 0xffff has a magnitude of 1.
+func CannyGray16(sigma, low, high float64, img Channel) *image.Gray16 {
+	return cannyGray16(DefaultScheduler, sigma, low, high, img)
+}
+
+// Canny edge states.
+const (
+	cannyNone = iota
+	cannyWeak
+	cannyStrong
+)
+
+// cannyGray16 implements CannyGray16 using the given Scheduler.
+func cannyGray16(s *Scheduler, sigma, low, high float64, img Channel) *image.Gray16 {
+	bounds := img.Bounds()
+	var f FloatChannel = ChannelToFloat(img)
+	if sigma > 0 {
+		f = exactGaussianBlurFloat(s, sigma, f)
+	}
+	gx, gy := gradientFloat(s, f, sobelWeights)
+
+	magnitude := NewFloatGray(bounds)
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				magnitude.SetFloat(pt.X, pt.Y, float32(math.Hypot(
+					float64(gx.FloatAt(pt.X, pt.Y)),
+					float64(gy.FloatAt(pt.X, pt.Y)),
+				)))
+			},
+		),
+	)(bounds)
+
+	// Suppress the values that aren't maxima along the gradient direction,
+	// quantised to one of four neighbouring pairs, and classify the rest. One
+	// side of each comparison is strict so that plateaus two pixels wide
+	// become one.
+	w := bounds.Dx()
+	state := make([]uint8, w*bounds.Dy())
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				m := magnitude.FloatAt(pt.X, pt.Y)
+				if float64(m) < low || m == 0 {
+					return
+				}
+
+				theta := math.Atan2(float64(gy.FloatAt(pt.X, pt.Y)), float64(gx.FloatAt(pt.X, pt.Y)))
+				if theta < 0 {
+					theta += math.Pi
+				}
+				var d image.Point
+				switch {
+				case theta < math.Pi/8 || theta >= 7*math.Pi/8:
+					d = image.Pt(1, 0)
+				case theta < 3*math.Pi/8:
+					d = image.Pt(1, 1)
+				case theta < 5*math.Pi/8:
+					d = image.Pt(0, 1)
+				default:
+					d = image.Pt(-1, 1)
+				}
+				if m <= magnitude.FloatAt(pt.X+d.X, pt.Y+d.Y) || m < magnitude.FloatAt(pt.X-d.X, pt.Y-d.Y) {
+					return
+				}
+
+				i := (pt.Y-bounds.Min.Y)*w + pt.X - bounds.Min.X
+				if float64(m) >= high {
+					state[i] = cannyStrong
+				} else {
+					state[i] = cannyWeak
+				}
+			},
+		),
+	)(bounds)
+
+	// Follow the weak edges connected to the strong ones.
+	dst := image.NewGray16(bounds)
+	var stack []image.Point
+	for i, v := range state {
+		if v == cannyStrong {
+			stack = append(stack, image.Pt(bounds.Min.X+i%w, bounds.Min.Y+i/w))
+		}
+	}
+	for len(stack) > 0 {
+		pt := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		dst.SetGray16(pt.X, pt.Y, color.Gray16{Y: math.MaxUint16})
+
+		for y := pt.Y - 1; y <= pt.Y+1; y++ {
+			for x := pt.X - 1; x <= pt.X+1; x++ {
+				if x < bounds.Min.X || x >= bounds.Max.X || y < bounds.Min.Y || y >= bounds.Max.Y {
+					continue
+				}
+				if i := (y-bounds.Min.Y)*w + x - bounds.Min.X; state[i] == cannyWeak {
+					state[i] = cannyStrong
+					stack = append(stack, image.Pt(x, y))
+				}
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,152 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// stepGray16 returns a channel that is high where in returns true and low
+// elsewhere.
+func stepGray16(rect image.Rectangle, low, high uint16, in func(pt image.Point) bool) *image.Gray16 {
+	img := image.NewGray16(rect)
+	AllPointsRP(
+		func(pt image.Point) {
+			v := low
+			if in(pt) {
+				v = high
+			}
+			img.SetGray16(pt.X, pt.Y, color.Gray16{Y: v})
+		},
+	)(rect)
+	return img
+}
+
+func TestGradientGray16(t *testing.T) {
+	rect := image.Rect(-5, 3, 25, 33)
+	vertical := stepGray16(rect, 0, math.MaxUint16, func(pt image.Point) bool { return pt.X >= 10 })
+	horizontal := stepGray16(rect, 0, math.MaxUint16, func(pt image.Point) bool { return pt.Y >= 20 })
+
+	for name, gradient := range map[string]func(Channel) (*image.Gray16, *image.Gray16){
+		"Sobel":   SobelGray16,
+		"Scharr":  ScharrGray16,
+		"Prewitt": PrewittGray16,
+	} {
+		for _, test := range []struct {
+			img         *image.Gray16
+			edge        func(pt image.Point) bool
+			orientation uint16
+		}{
+			{vertical, func(pt image.Point) bool { return pt.X == 9 || pt.X == 10 }, 0},
+			{horizontal, func(pt image.Point) bool { return pt.Y == 19 || pt.Y == 20 }, 0x4000},
+		} {
+			magnitude, orientation := gradient(test.img)
+			if magnitude.Rect != rect || orientation.Rect != rect {
+				t.Errorf("%s gradients have bounds %v and %v, expected %v", name, magnitude.Rect, orientation.Rect, rect)
+			}
+
+			AllPointsRP(
+				func(pt image.Point) {
+					m, o := magnitude.Gray16At(pt.X, pt.Y).Y, orientation.Gray16At(pt.X, pt.Y).Y
+					if !test.edge(pt) {
+						if m != 0 || o != 0 {
+							t.Errorf("%s gradient at %v is %#04x towards %#04x, expected none", name, pt, m, o)
+						}
+					} else if m != math.MaxUint16 || o != test.orientation {
+						t.Errorf("%s gradient at %v is %#04x towards %#04x, expected 0xffff towards %#04x",
+							name, pt, m, o, test.orientation)
+					}
+				},
+			)(rect)
+		}
+
+		// Decreasing steps point the other way.
+		inverted := stepGray16(rect, math.MaxUint16, 0, func(pt image.Point) bool { return pt.X >= 10 })
+		if _, o := gradient(inverted); o.Gray16At(10, 10).Y != 0x8000 {
+			t.Errorf("%s orientation of decreasing step is %#04x, expected 0x8000", name, o.Gray16At(10, 10).Y)
+		}
+	}
+}
+
+func TestLaplacianOfGaussianGray16(t *testing.T) {
+	rect := image.Rect(0, 0, 40, 20)
+	img := stepGray16(rect, 0x2000, 0xe000, func(pt image.Point) bool { return pt.X >= 20 })
+
+	log := LaplacianOfGaussianGray16(2, img)
+	if log.Rect != rect {
+		t.Errorf("Laplacian of Gaussian has bounds %v, expected %v", log.Rect, rect)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		if v := log.Gray16At(2, y).Y; v != 0x8000 {
+			t.Errorf("Laplacian of Gaussian of a uniform region is %#04x at %v", v, image.Pt(2, y))
+		}
+		if v := log.Gray16At(18, y).Y; v <= 0x8000 {
+			t.Errorf("Laplacian of Gaussian on the dark side is %#04x at %v", v, image.Pt(18, y))
+		}
+		if v := log.Gray16At(21, y).Y; v >= 0x8000 {
+			t.Errorf("Laplacian of Gaussian on the light side is %#04x at %v", v, image.Pt(21, y))
+		}
+	}
+}
+
+func TestCannyGray16(t *testing.T) {
+	rect := image.Rect(0, 0, 60, 60)
+	square := image.Rect(15, 20, 45, 40)
+	img := stepGray16(rect, 0x1000, 0xf000, func(pt image.Point) bool { return pt.In(square) })
+
+	edges := CannyGray16(1.5, 0.1, 0.3, img)
+	if edges.Rect != rect {
+		t.Errorf("Canny edges have bounds %v, expected %v", edges.Rect, rect)
+	}
+
+	// The edges should be one pixel wide and next to the square's boundary.
+	AllPointsRP(
+		func(pt image.Point) {
+			v := edges.Gray16At(pt.X, pt.Y).Y
+			if v != 0 && v != math.MaxUint16 {
+				t.Errorf("Canny edge is %#04x at %v", v, pt)
+			}
+			if v != 0 && (!pt.In(square.Inset(-1)) || pt.In(square.Inset(1))) {
+				t.Errorf("Canny edge at %v is away from the square", pt)
+			}
+		},
+	)(rect)
+	for y := square.Min.Y + 3; y < square.Max.Y-3; y++ {
+		var n int
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if edges.Gray16At(x, y).Y != 0 {
+				n++
+			}
+		}
+		if n != 2 {
+			t.Errorf("Canny found %d edges in row %d, expected 2", n, y)
+		}
+	}
+
+	// Weak edges are only kept when connected to strong ones.
+	weak := stepGray16(rect, 0, 0x4000, func(pt image.Point) bool { return pt.Y >= 30 })
+	edges = CannyGray16(1, 0.1, 0.5, weak)
+	AllPointsRP(
+		func(pt image.Point) {
+			if edges.Gray16At(pt.X, pt.Y).Y != 0 {
+				t.Errorf("Canny found an unconnected weak edge at %v", pt)
+			}
+		},
+	)(rect)
+
+	// Fade the step gradually so that it doesn't introduce other edges.
+	mixed := image.NewGray16(rect)
+	AllPointsRP(
+		func(pt image.Point) {
+			v := math.Max(0.25, 1-0.0375*float64(pt.X))
+			mixed.SetGray16(pt.X, pt.Y, color.Gray16{Y: uint16(v * math.MaxUint16)})
+		},
+	)(image.Rect(0, 30, 60, 60))
+	edges = CannyGray16(1, 0.1, 0.5, mixed)
+	for x := 0; x < 60; x++ {
+		if edges.Gray16At(x, 29).Y == 0 && edges.Gray16At(x, 30).Y == 0 {
+			t.Errorf("Canny didn't follow the weak edge at %d", x)
+		}
+	}
+}
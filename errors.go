@@ -0,0 +1,197 @@
+package imageutil
+
+import (
+	"errors"
+	"image"
+	"sync"
+)
+
+// ERP is an error-returning rectangle processor, any function that accepts a
+// single image.Rectangle value as an argument and returns an error.
+type ERP func(image.Rectangle) error
+
+// EPP is an error-returning point processor, any function that accepts a
+// single image.Point value as an argument and returns an error.
+type EPP func(image.Point) error
+
+// noopERP is an ERP that does nothing.
+var (
+	noopERP = func(image.Rectangle) error { return nil }
+)
+
+// Errors is a collection of errors that is safe for concurrent use, such as
+// the errors returned by ERPs running in separate Go routines.
+type Errors struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// Add adds a non-nil error to the collection.
+func (e *Errors) Add(err error) {
+	if err == nil {
+		return
+	}
+
+	e.mu.Lock()
+	e.errs = append(e.errs, err)
+	e.mu.Unlock()
+}
+
+// Failed reports whether any errors have been added to the collection.
+func (e *Errors) Failed() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.errs) > 0
+}
+
+// Err returns nil if the collection is empty, the only error if it holds
+// exactly one, and all of the errors joined otherwise.
+func (e *Errors) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch len(e.errs) {
+	case 0:
+		return nil
+	case 1:
+		return e.errs[0]
+	}
+	return errors.Join(e.errs...)
+}
+
+// PointsERP returns an ERP that runs a given EPP at each point within an
+// input rectangle starting at a given offset and seperated by the given
+// horizontal and vertical stride. It stops at and returns the first error.
+func PointsERP(offset image.Point, strideH, strideV int, epp EPP) ERP {
+	if strideH <= 0 || strideV <= 0 || offset.X < 0 || offset.Y < 0 {
+		return noopERP
+	}
+
+	return func(rect image.Rectangle) error {
+		origin := rect.Min.Add(offset)
+		for y := origin.Y; y < rect.Max.Y; y += strideV {
+			for x := origin.X; x < rect.Max.X; x += strideH {
+				if err := epp(image.Pt(x, y)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// AllPointsERP returns an ERP that runs a given EPP at every point within an
+// input rectangle.
+func AllPointsERP(epp EPP) ERP {
+	return PointsERP(image.Pt(0, 0), 1, 1, epp)
+}
+
+// RowsERP is the ERP counterpart to RowsRP. No further rows are processed
+// once the given ERP returns an error.
+func RowsERP(height int, erp ERP) ERP {
+	if height <= 0 {
+		return noopERP
+	}
+
+	return func(rect image.Rectangle) error {
+
+		// Process all but the last row.
+		y := rect.Min.Y
+		for ; y < rect.Max.Y-height; y += height {
+			if err := erp(image.Rect(rect.Min.X, y, rect.Max.X, y+height)); err != nil {
+				return err
+			}
+		}
+
+		// Process the last row.
+		return erp(image.Rect(rect.Min.X, y, rect.Max.X, rect.Max.Y))
+	}
+}
+
+// ColumnsERP is the ERP counterpart to ColumnsRP. No further columns are
+// processed once the given ERP returns an error.
+func ColumnsERP(width int, erp ERP) ERP {
+	if width <= 0 {
+		return noopERP
+	}
+
+	return func(rect image.Rectangle) error {
+
+		// Process all but the last column.
+		x := rect.Min.X
+		for ; x < rect.Max.X-width; x += width {
+			if err := erp(image.Rect(x, rect.Min.Y, x+width, rect.Max.Y)); err != nil {
+				return err
+			}
+		}
+
+		// Process the last column.
+		return erp(image.Rect(x, rect.Min.Y, rect.Max.X, rect.Max.Y))
+	}
+}
+
+// NRowsERP calls the given ERP on each of n horizontal rectangles that span
+// the input rectangle.
+func NRowsERP(n int, erp ERP) ERP {
+	if n <= 0 {
+		return noopERP
+	}
+
+	return func(rect image.Rectangle) error {
+		height := (rect.Dy() + n - 1) / n
+		return RowsERP(height, erp)(rect)
+	}
+}
+
+// NColumnsERP calls the given ERP on each of n vertical rectangles that span
+// the input rectangle.
+func NColumnsERP(n int, erp ERP) ERP {
+	if n <= 0 {
+		return noopERP
+	}
+
+	return func(rect image.Rectangle) error {
+		width := (rect.Dx() + n - 1) / n
+		return ColumnsERP(width, erp)(rect)
+	}
+}
+
+// ConcurrentERP wraps a given ERP in a Go routine and the necessary WaitGroup
+// operations to ensure that completion can be tracked. Errors returned from
+// within the Go routine are added to errs, and once errs holds any errors no
+// further Go routines are started and errs.Err() is returned instead.
+func ConcurrentERP(w *sync.WaitGroup, errs *Errors, erp ERP) ERP {
+	return func(rect image.Rectangle) error {
+		if errs.Failed() {
+			return errs.Err()
+		}
+
+		w.Add(1)
+		go func() {
+			errs.Add(erp(rect))
+			w.Done()
+		}()
+		return nil
+	}
+}
+
+// QuickRowsERP is the ERP counterpart to QuickRowsRP. No further rectangles
+// are processed once any returns an error, and the errors returned by those
+// already running are joined.
+func QuickRowsERP(erp ERP) ERP {
+	return DefaultScheduler.RowsERP(erp)
+}
+
+// QuickColumnsERP is the ERP counterpart to QuickColumnsRP. No further
+// rectangles are processed once any returns an error, and the errors returned
+// by those already running are joined.
+func QuickColumnsERP(erp ERP) ERP {
+	return DefaultScheduler.ColumnsERP(erp)
+}
+
+// QuickERP is the ERP counterpart to QuickRP. No further tiles are processed
+// once any returns an error, and the errors returned by those already running
+// are joined.
+func QuickERP(erp ERP) ERP {
+	return DefaultScheduler.ERP(erp)
+}
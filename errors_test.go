@@ -0,0 +1,95 @@
+package imageutil
+
+import (
+	"errors"
+	"image"
+	"sync"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	if errs.Failed() || errs.Err() != nil {
+		t.Error("empty collection reported an error")
+	}
+
+	errA := errors.New("a")
+	errs.Add(nil)
+	errs.Add(errA)
+	if err := errs.Err(); err != errA {
+		t.Error("expected the only error, found", err)
+	}
+
+	errB := errors.New("b")
+	errs.Add(errB)
+	if err := errs.Err(); !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Error("expected both errors to be joined, found", err)
+	}
+}
+
+func TestAllPointsERP(t *testing.T) {
+	testErr := errors.New("test error")
+
+	n := 0
+	err := AllPointsERP(func(pt image.Point) error {
+		n++
+		if pt == image.Pt(4, 2) {
+			return testErr
+		}
+		return nil
+	})(image.Rect(0, 0, 10, 10))
+
+	if err != testErr {
+		t.Error("expected the test error, found", err)
+	}
+
+	if n != 25 {
+		t.Error("point processor ran", n, "times, expected 25")
+	}
+}
+
+func TestConcurrentERP(t *testing.T) {
+	testErr := errors.New("test error")
+
+	var (
+		w    sync.WaitGroup
+		errs Errors
+	)
+
+	erp := ConcurrentERP(&w, &errs, func(image.Rectangle) error {
+		return testErr
+	})
+
+	if err := erp(image.Rect(0, 0, 1, 1)); err != nil {
+		t.Error("expected no error before any Go routine finished, found", err)
+	}
+	w.Wait()
+
+	if err := erp(image.Rect(0, 0, 1, 1)); err != testErr {
+		t.Error("expected the test error once a Go routine failed, found", err)
+	}
+	w.Wait()
+}
+
+func TestQuickERP(t *testing.T) {
+	testErr := errors.New("test error")
+
+	err := QuickERP(
+		RowsERP(1, func(rect image.Rectangle) error {
+			if rect.Min.Y == 500 {
+				return testErr
+			}
+			return nil
+		}),
+	)(image.Rect(0, 0, 10, 1000))
+
+	if !errors.Is(err, testErr) {
+		t.Error("expected the test error, found", err)
+	}
+
+	if err := QuickERP(AllPointsERP(func(image.Point) error {
+		return nil
+	}))(image.Rect(0, 0, 100, 100)); err != nil {
+		t.Error("expected no error, found", err)
+	}
+}
@@ -6,73 +6,304 @@ import (
 	"math"
 )
 
+// Invert concurrently inverts the colors of an ImageReader, leaving alpha
+// unchanged, and returns the result as a new image of the same type. Images
+// of types this package doesn't know are returned as an *image.NRGBA64.
 func Invert(img ImageReader) ImageReader {
-	var (
-		invertedImage ImageReadWriter
-		pp            PP
-	)
+	return invert(DefaultScheduler, img, false)
+}
+
+// InvertProgress is Invert, reporting its progress to the given Progress.
+func InvertProgress(img ImageReader, p Progress) ImageReader {
+	return invert(DefaultScheduler.WithProgress(p), img, false)
+}
+
+// InvertWithAlpha is Invert, also inverting alpha. Images without an alpha
+// channel, such as an *image.Gray, remain opaque.
+func InvertWithAlpha(img ImageReader) ImageReader {
+	return invert(DefaultScheduler, img, true)
+}
 
+// invert implements Invert and InvertWithAlpha using the given Scheduler.
+func invert(s *Scheduler, img ImageReader, alpha bool) ImageReader {
 	bounds := img.Bounds()
-	switch img.(type) {
-	case *image.Alpha, *image.Alpha16:
-		return img
+
+	var (
+		inverted ImageReader
+		rp       RP
+	)
+	switch src := img.(type) {
 	case *image.Gray:
-		invertedImage = image.NewGray(bounds)
-		pp = func(pt image.Point) {
-			c := img.At(pt.X, pt.Y).(color.Gray)
-			c.Y = math.MaxInt8 - c.Y
-			invertedImage.Set(pt.X, pt.Y, c)
-		}
+		dst := image.NewGray(bounds)
+		inverted = dst
+		rp = pixRP(dst.Pix, dst.PixOffset, src.Pix, src.PixOffset, 1,
+			func(d, s []uint8) {
+				d[0] = math.MaxUint8 - s[0]
+			},
+		)
 	case *image.Gray16:
-		invertedImage = image.NewGray16(bounds)
-		pp = func(pt image.Point) {
-			c := img.At(pt.X, pt.Y).(color.Gray16)
-			c.Y = math.MaxInt8 - c.Y
-			invertedImage.Set(pt.X, pt.Y, c)
-		}
+		dst := image.NewGray16(bounds)
+		inverted = dst
+		rp = pixRP(dst.Pix, dst.PixOffset, src.Pix, src.PixOffset, 2,
+			func(d, s []uint8) {
+				put16(d, math.MaxUint16-get16(s))
+			},
+		)
+	case *image.Alpha:
+		dst := image.NewAlpha(bounds)
+		inverted = dst
+		rp = pixRP(dst.Pix, dst.PixOffset, src.Pix, src.PixOffset, 1,
+			func(d, s []uint8) {
+				d[0] = invertAlpha8(s[0], alpha)
+			},
+		)
+	case *image.Alpha16:
+		dst := image.NewAlpha16(bounds)
+		inverted = dst
+		rp = pixRP(dst.Pix, dst.PixOffset, src.Pix, src.PixOffset, 2,
+			func(d, s []uint8) {
+				put16(d, invertAlpha16(get16(s), alpha))
+			},
+		)
 	case *image.NRGBA:
-		invertedImage = image.NewNRGBA(bounds)
-		pp = func(pt image.Point) {
-			c := img.At(pt.X, pt.Y).(color.NRGBA)
-			c.R = math.MaxUint8 - c.R
-			c.G = math.MaxUint8 - c.G
-			c.B = math.MaxUint8 - c.B
-			invertedImage.Set(pt.X, pt.Y, c)
-		}
+		dst := image.NewNRGBA(bounds)
+		inverted = dst
+		rp = pixRP(dst.Pix, dst.PixOffset, src.Pix, src.PixOffset, 4,
+			func(d, s []uint8) {
+				d[0] = math.MaxUint8 - s[0]
+				d[1] = math.MaxUint8 - s[1]
+				d[2] = math.MaxUint8 - s[2]
+				d[3] = invertAlpha8(s[3], alpha)
+			},
+		)
 	case *image.NRGBA64:
-		invertedImage = image.NewNRGBA64(bounds)
-		pp = func(pt image.Point) {
-			c := img.At(pt.X, pt.Y).(color.NRGBA64)
-			c.R = math.MaxUint16 - c.R
-			c.G = math.MaxUint16 - c.G
-			c.B = math.MaxUint16 - c.B
-			invertedImage.Set(pt.X, pt.Y, c)
-		}
+		dst := image.NewNRGBA64(bounds)
+		inverted = dst
+		rp = pixRP(dst.Pix, dst.PixOffset, src.Pix, src.PixOffset, 8,
+			func(d, s []uint8) {
+				put16(d[0:], math.MaxUint16-get16(s[0:]))
+				put16(d[2:], math.MaxUint16-get16(s[2:]))
+				put16(d[4:], math.MaxUint16-get16(s[4:]))
+				put16(d[6:], invertAlpha16(get16(s[6:]), alpha))
+			},
+		)
 	case *image.RGBA:
-		invertedImage = image.NewNRGBA(bounds)
-		pp = func(pt image.Point) {
-			c := img.At(pt.X, pt.Y).(color.RGBA)
-			c.R = c.A - c.R
-			c.G = c.A - c.G
-			c.B = c.A - c.B
-			invertedImage.Set(pt.X, pt.Y, c)
-		}
+		dst := image.NewRGBA(bounds)
+		inverted = dst
+		rp = pixRP(dst.Pix, dst.PixOffset, src.Pix, src.PixOffset, 4,
+			func(d, s []uint8) {
+				a := uint32(s[3])
+				for i := 0; i < 3; i++ {
+					d[i] = uint8(invertPremultiplied(uint32(s[i]), a, math.MaxUint8, alpha))
+				}
+				d[3] = invertAlpha8(s[3], alpha)
+			},
+		)
 	case *image.RGBA64:
-		invertedImage = image.NewRGBA64(bounds)
-		pp = func(pt image.Point) {
-			c := img.At(pt.X, pt.Y).(color.RGBA64)
-			c.R = c.A - c.R
-			c.G = c.A - c.G
-			c.B = c.A - c.B
-			invertedImage.Set(pt.X, pt.Y, c)
+		dst := image.NewRGBA64(bounds)
+		inverted = dst
+		rp = pixRP(dst.Pix, dst.PixOffset, src.Pix, src.PixOffset, 8,
+			func(d, s []uint8) {
+				a := uint32(get16(s[6:]))
+				for i := 0; i < 6; i += 2 {
+					put16(d[i:], uint16(invertPremultiplied(uint32(get16(s[i:])), a, math.MaxUint16, alpha)))
+				}
+				put16(d[6:], invertAlpha16(uint16(a), alpha))
+			},
+		)
+	case *image.CMYK:
+		dst := image.NewCMYK(bounds)
+		inverted = dst
+		rp = pixRP(dst.Pix, dst.PixOffset, src.Pix, src.PixOffset, 4,
+			func(d, s []uint8) {
+				r, g, b := color.CMYKToRGB(s[0], s[1], s[2], s[3])
+				d[0], d[1], d[2], d[3] = color.RGBToCMYK(
+					math.MaxUint8-r,
+					math.MaxUint8-g,
+					math.MaxUint8-b,
+				)
+			},
+		)
+	case *image.Paletted:
+		p := make(color.Palette, len(src.Palette))
+		for i, c := range src.Palette {
+			p[i] = invertNRGBA64(c, alpha)
+		}
+		dst := image.NewPaletted(bounds, p)
+		inverted = dst
+		copier := samePixCopier(dst.Pix, dst.Stride, dst.PixOffset, src.Pix, src.Stride, src.PixOffset, 1)
+		rp = func(r image.Rectangle) {
+			copier(r, r.Min)
+		}
+	case *image.YCbCr:
+		dst := image.NewYCbCr(bounds, src.SubsampleRatio)
+		return invertYCbCr(s, dst, nil, src, nil, alpha)
+	case *YCbCr:
+		dst := image.NewYCbCr(bounds, src.SubsampleRatio)
+		invertYCbCr(s, dst, nil, src.YCbCr, nil, alpha)
+		return &YCbCr{dst}
+	case *image.NYCbCrA:
+		dst := image.NewNYCbCrA(bounds, src.SubsampleRatio)
+		return invertYCbCr(s, &dst.YCbCr, dst, &src.YCbCr, src, alpha)
+	case *NYCbCrA:
+		dst := image.NewNYCbCrA(bounds, src.SubsampleRatio)
+		invertYCbCr(s, &dst.YCbCr, dst, &src.YCbCr, src.NYCbCrA, alpha)
+		return &NYCbCrA{dst}
+	default:
+		dst := image.NewNRGBA64(bounds)
+		inverted = dst
+		rp = AllPointsRP(
+			func(pt image.Point) {
+				dst.SetNRGBA64(pt.X, pt.Y, invertNRGBA64(img.At(pt.X, pt.Y), alpha))
+			},
+		)
+	}
+
+	s.RP(rp)(bounds)
+	return inverted
+}
+
+// pixRP returns an RP that calls f with each pixel of bpp bytes of a source
+// Pix slice and the pixel at the same coordinates of a destination Pix slice.
+func pixRP(dPix []uint8, dOffset func(x, y int) int, sPix []uint8, sOffset func(x, y int) int, bpp int, f func(d, s []uint8)) RP {
+	return func(r image.Rectangle) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			di, si := dOffset(r.Min.X, y), sOffset(r.Min.X, y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				f(dPix[di:di+bpp], sPix[si:si+bpp])
+				di += bpp
+				si += bpp
+			}
 		}
 	}
+}
+
+// get16 returns the big-endian uint16 at the start of b.
+func get16(b []uint8) uint16 {
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+// put16 sets the start of b to the big-endian uint16 v.
+func put16(b []uint8, v uint16) {
+	b[0] = uint8(v >> 8)
+	b[1] = uint8(v)
+}
+
+// invertAlpha8 returns the inverse of an 8-bit alpha if invert is true, and
+// the alpha itself otherwise.
+func invertAlpha8(a uint8, invert bool) uint8 {
+	if invert {
+		return math.MaxUint8 - a
+	}
+	return a
+}
+
+// invertAlpha16 is the 16-bit counterpart to invertAlpha8.
+func invertAlpha16(a uint16, invert bool) uint16 {
+	if invert {
+		return math.MaxUint16 - a
+	}
+	return a
+}
+
+// invertPremultiplied returns the inverse of a color component c that is
+// premultiplied by the alpha a, with both at most max. If alpha is true, the
+// result is premultiplied by the inverse alpha instead, and the colors of
+// fully transparent pixels, which are lost, become white.
+func invertPremultiplied(c, a, max uint32, alpha bool) uint32 {
+	if !alpha {
+		return a - c
+	}
+	if a == 0 {
+		return max
+	}
+	return ((a-c)*(max-a) + a/2) / a
+}
+
+// invertNRGBA64 returns the inverse of a color at the precision of
+// color.NRGBA64, also inverting alpha if alpha is true.
+func invertNRGBA64(c color.Color, alpha bool) color.NRGBA64 {
+	c1 := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+	return color.NRGBA64{
+		R: math.MaxUint16 - c1.R,
+		G: math.MaxUint16 - c1.G,
+		B: math.MaxUint16 - c1.B,
+		A: invertAlpha16(c1.A, alpha),
+	}
+}
+
+// invertChroma returns the chroma of the inverse of a color. Since luma and
+// chroma are affine in red, green and blue, inverting the color reflects its
+// chroma about 128, which is clamped to 255 for a chroma of 0.
+func invertChroma(c uint8) uint8 {
+	if c == 0 {
+		return math.MaxUint8
+	}
+	return uint8(256 - int(c))
+}
+
+// invertYCbCr implements invert for YCbCr images by concurrently inverting
+// the luma and chroma planes of src into dst, along with the alpha plane of
+// srcA into dstA if both aren't nil, and returns dstA if it isn't nil and dst
+// otherwise. The images must have the same bounds and subsampling ratio.
+func invertYCbCr(s *Scheduler, dst *image.YCbCr, dstA *image.NYCbCrA, src *image.YCbCr, srcA *image.NYCbCrA, alpha bool) ImageReader {
+	var inverted ImageReader = dst
+	if dstA != nil {
+		inverted = dstA
+	}
+
+	bounds := dst.Rect
+	if bounds.Empty() {
+		return inverted
+	}
+
+	// Group the columns and rows of pixels by the chroma sample they share,
+	// using the same offsets as COffset.
+	cw := dst.CStride
+	ch := len(dst.Cb) / cw
+	columns := make([][]int, cw)
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		ci := dst.COffset(x, bounds.Min.Y) - dst.COffset(bounds.Min.X, bounds.Min.Y)
+		columns[ci] = append(columns[ci], x)
+	}
+	rows := make([][]int, ch)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		ci := (dst.COffset(bounds.Min.X, y) - dst.COffset(bounds.Min.X, bounds.Min.Y)) / cw
+		rows[ci] = append(rows[ci], y)
+	}
 
-	QuickRP(AllPointsRP(pp))(bounds)
-	return invertedImage
+	// Process a chroma sample at a time along with the pixels that share it,
+	// so that no sample is shared between rectangles.
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				di := pt.Y*dst.CStride + pt.X
+				si := pt.Y*src.CStride + pt.X
+				dst.Cb[di] = invertChroma(src.Cb[si])
+				dst.Cr[di] = invertChroma(src.Cr[si])
+
+				for _, y := range rows[pt.Y] {
+					for _, x := range columns[pt.X] {
+						dst.Y[dst.YOffset(x, y)] = math.MaxUint8 - src.Y[src.YOffset(x, y)]
+						if dstA != nil && srcA != nil {
+							dstA.A[dstA.AOffset(x, y)] = invertAlpha8(srcA.A[srcA.AOffset(x, y)], alpha)
+						}
+					}
+				}
+			},
+		),
+	)(image.Rect(0, 0, cw, ch))
+
+	return inverted
 }
 
 func EdgesGray16(radius int, img Channel) *image.Gray16 {
+	return edgesGray16(DefaultScheduler, nil, radius, img)
+}
+
+// edgesGray16 implements EdgesGray16 using the given Scheduler, reporting its
+// progress to the given Progress.
+func edgesGray16(s *Scheduler, p Progress, radius int, img Channel) *image.Gray16 {
 	bounds := img.Bounds()
 	edgeImage := image.NewGray16(bounds)
 	if radius < 1 {
@@ -80,10 +311,10 @@ func EdgesGray16(radius int, img Channel) *image.Gray16 {
 	}
 
 	// Compute the horizontal and vertical averages.
-	hGA := RowAverageGray16(radius, img)
-	vGA := ColumnAverageGray16(radius, img)
+	hGA := rowAverageGray16(s.WithProgress(p.stage(0, 3)), radius, img)
+	vGA := columnAverageGray16(s.WithProgress(p.stage(1, 3)), radius, img)
 
-	QuickRP(
+	s.WithProgress(p.stage(2, 3)).RP(
 		AllPointsRP(
 			func(pt image.Point) {
 				e := float64(hGA.Gray16At(pt.X, pt.Y).Y)
@@ -102,10 +333,90 @@ func EdgesGray16(radius int, img Channel) *image.Gray16 {
 	return edgeImage
 }
 
+// EdgesGray16Border is EdgesGray16, treating the coordinates beyond the
+// bounds of the Channel as the given Border does rather than as zero.
+func EdgesGray16Border(radius int, border Border, img Channel) *image.Gray16 {
+	return edgesGray16Border(DefaultScheduler, nil, radius, border, img)
+}
+
+// edgesGray16Border implements EdgesGray16Border using the given Scheduler,
+// reporting its progress to the given Progress.
+func edgesGray16Border(s *Scheduler, p Progress, radius int, border Border, img Channel) *image.Gray16 {
+	bounds := img.Bounds()
+	edgeImage := image.NewGray16(bounds)
+	if radius < 1 {
+		return edgeImage
+	}
+
+	// Compute the averages of the windows ending and starting at each pixel
+	// horizontally and vertically.
+	w := windowAverageGray16(s.WithProgress(p.stage(0, 5)), -radius+1, 0, border, false, img)
+	e := windowAverageGray16(s.WithProgress(p.stage(1, 5)), 0, radius-1, border, false, img)
+	n := windowAverageGray16(s.WithProgress(p.stage(2, 5)), -radius+1, 0, border, true, img)
+	so := windowAverageGray16(s.WithProgress(p.stage(3, 5)), 0, radius-1, border, true, img)
+
+	s.WithProgress(p.stage(4, 5)).RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				dx := math.Abs(float64(e.Gray16At(pt.X, pt.Y).Y) - float64(w.Gray16At(pt.X, pt.Y).Y))
+				dy := math.Abs(float64(so.Gray16At(pt.X, pt.Y).Y) - float64(n.Gray16At(pt.X, pt.Y).Y))
+				edgeImage.SetGray16(pt.X, pt.Y, color.Gray16{
+					Y: uint16(math.Max(dx, dy)),
+				})
+			},
+		),
+	)(bounds)
+
+	return edgeImage
+}
+
+// EdgesFloat is the FloatChannel counterpart to EdgesGray16, computing the
+// averages and their differences without rounding or clamping.
+func EdgesFloat(radius int, img FloatChannel) *FloatGray {
+	return edgesFloat(DefaultScheduler, nil, radius, img)
+}
+
+// edgesFloat implements EdgesFloat using the given Scheduler, reporting its
+// progress to the given Progress.
+func edgesFloat(s *Scheduler, p Progress, radius int, img FloatChannel) *FloatGray {
+	bounds := img.Bounds()
+	edgeImage := NewFloatGray(bounds)
+	if radius < 1 {
+		return edgeImage
+	}
+
+	// Compute the horizontal and vertical averages.
+	hGA := rowAverageFloat(s.WithProgress(p.stage(0, 3)), radius, img)
+	vGA := columnAverageFloat(s.WithProgress(p.stage(1, 3)), radius, img)
+
+	s.WithProgress(p.stage(2, 3)).RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				e := hGA.FloatAt(pt.X, pt.Y)
+				w := hGA.FloatAt(pt.X-radius+1, pt.Y)
+				n := vGA.FloatAt(pt.X, pt.Y)
+				s := vGA.FloatAt(pt.X, pt.Y-radius+1)
+				edgeImage.SetFloat(pt.X, pt.Y,
+					float32(math.Max(math.Abs(float64(e-w)), math.Abs(float64(s-n)))),
+				)
+			},
+		),
+	)(bounds)
+
+	return edgeImage
+}
+
 func EdgesNRGBA64(radius int, img *image.NRGBA64) *image.NRGBA64 {
+	return EdgesNRGBA64Progress(radius, img, nil)
+}
+
+// EdgesNRGBA64Progress is EdgesNRGBA64, reporting its progress to the given
+// Progress.
+func EdgesNRGBA64Progress(radius int, img *image.NRGBA64, p Progress) *image.NRGBA64 {
+	s := DefaultScheduler
 	r, g, b, a := NRGBA64ToChannels(img)
-	r = EdgesGray16(radius, r)
-	g = EdgesGray16(radius, g)
-	b = EdgesGray16(radius, b)
-	return ChannelsToNRGBA64(r, g, b, a)
+	r = edgesGray16(s, p.stage(0, 4), radius, r)
+	g = edgesGray16(s, p.stage(1, 4), radius, g)
+	b = edgesGray16(s, p.stage(2, 4), radius, b)
+	return channelsToNRGBA64(s.WithProgress(p.stage(3, 4)), r, g, b, a)
 }
@@ -0,0 +1,144 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestInvert(t *testing.T) {
+	sources := copyTestSources()
+	sources["NYCbCrA"] = ConvertToNYCbCrA(randomNRGBA64(copyTestRect), image.YCbCrSubsampleRatio420).NYCbCrA
+	sources["YCbCrSubImage"] = randomYCbCr(copyTestRect, image.YCbCrSubsampleRatio420).SubImage(image.Rect(-2, 6, 51, 47))
+	sources["Opaque"] = opaqueImage{randomNRGBA64(copyTestRect)}
+
+	// Conversions through luma and chroma or CMYK lose a little precision.
+	tolerance := map[string]uint32{
+		"CMYK":          0x400,
+		"YCbCr444":      0x400,
+		"YCbCr420":      0x400,
+		"YCbCr411":      0x400,
+		"YCbCrSubImage": 0x400,
+		"NYCbCrA":       0x400,
+	}
+
+	for name, src := range sources {
+		for _, alpha := range []bool{false, true} {
+			var dst ImageReader
+			if alpha {
+				dst = InvertWithAlpha(src)
+			} else {
+				dst = Invert(src)
+			}
+
+			if _, ok := src.(opaqueImage); ok {
+				if _, ok := dst.(*image.NRGBA64); !ok {
+					t.Errorf("%s inverted to %T, expected *image.NRGBA64", name, dst)
+				}
+			} else if reflect.TypeOf(dst) != reflect.TypeOf(src) {
+				t.Errorf("%s inverted to %T", name, dst)
+			}
+			if dst.Bounds() != src.Bounds() {
+				t.Errorf("%s inverted bounds %v, expected %v", name, dst.Bounds(), src.Bounds())
+			}
+
+			tol := tolerance[name]
+			if tol == 0 {
+				tol = 0x101
+			}
+
+			AllPointsRP(
+				func(pt image.Point) {
+					var expected color.Color
+					switch src.(type) {
+					case *image.Alpha, *image.Alpha16:
+						_, _, _, a := src.At(pt.X, pt.Y).RGBA()
+						expected = color.Alpha16{A: invertAlpha16(uint16(a), alpha)}
+					case *image.Gray, *image.Gray16, *image.CMYK, *image.YCbCr:
+						expected = invertNRGBA64(src.At(pt.X, pt.Y), false)
+					default:
+						expected = invertNRGBA64(src.At(pt.X, pt.Y), alpha)
+
+						// The colors of transparent pixels are lost to RGBA, so
+						// only their alpha can be compared.
+						if _, _, _, a := src.At(pt.X, pt.Y).RGBA(); alpha && a == 0 {
+							if _, _, _, a := dst.At(pt.X, pt.Y).RGBA(); a != 0xffff {
+								t.Errorf("%s inverted a transparent pixel at %v to alpha %v", name, pt, a)
+							}
+							return
+						}
+					}
+
+					er, eg, eb, ea := expected.RGBA()
+					ar, ag, ab, aa := dst.At(pt.X, pt.Y).RGBA()
+					for _, v := range [][2]uint32{{er, ar}, {eg, ag}, {eb, ab}, {ea, aa}} {
+						d := v[0] - v[1]
+						if v[1] > v[0] {
+							d = v[1] - v[0]
+						}
+						if d > tol {
+							t.Errorf("%s inverted with alpha %v to %v at %v, expected %v",
+								name, alpha, dst.At(pt.X, pt.Y), pt, expected)
+							return
+						}
+					}
+				},
+			)(src.Bounds())
+		}
+	}
+}
+
+func TestInvertInvolution(t *testing.T) {
+	for name, src := range copyTestSources() {
+		if _, ok := src.(*image.CMYK); ok {
+			continue
+		}
+		if !reflect.DeepEqual(pix(Invert(Invert(src))), pix(src)) {
+			t.Errorf("inverting %s twice changed it", name)
+		}
+	}
+}
+
+func TestEdgesGray16Border(t *testing.T) {
+	gray := ConvertToGray16(randomNRGBA64(copyTestRect))
+
+	// Away from the edges, the border makes no difference, although
+	// EdgesGray16 truncates its averages rather than rounding them.
+	expected := EdgesGray16(4, gray)
+	inner := copyTestRect.Inset(4)
+	for _, border := range []Border{BorderClamp, BorderMirror, BorderWrap, BorderZero, BorderShrink} {
+		edges := EdgesGray16Border(4, border, gray)
+		if edges.Rect != gray.Rect {
+			t.Errorf("border %d edges have bounds %v, expected %v", border, edges.Rect, gray.Rect)
+		}
+
+		AllPointsRP(
+			func(pt image.Point) {
+				e, g := int(expected.Gray16At(pt.X, pt.Y).Y), int(edges.Gray16At(pt.X, pt.Y).Y)
+				if e-g > 1 || g-e > 1 {
+					t.Errorf("border %d edges are %d at %v, expected %d", border, g, pt, e)
+				}
+			},
+		)(inner)
+	}
+
+	// A uniform channel has no edges, even at its borders, unless they're
+	// treated as zero.
+	uniform := image.NewGray16(copyTestRect)
+	AllPointsRP(
+		func(pt image.Point) {
+			uniform.SetGray16(pt.X, pt.Y, color.Gray16{Y: 0x8000})
+		},
+	)(copyTestRect)
+	for _, border := range []Border{BorderClamp, BorderMirror, BorderWrap, BorderShrink} {
+		edges := EdgesGray16Border(4, border, uniform)
+		AllPointsRP(
+			func(pt image.Point) {
+				if y := edges.Gray16At(pt.X, pt.Y).Y; y != 0 {
+					t.Errorf("border %d edges of a uniform channel are %d at %v", border, y, pt)
+				}
+			},
+		)(copyTestRect)
+	}
+}
@@ -0,0 +1,292 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// FloatChannel is any object that implements ImageReader as well as providing
+// a method for getting float32 values at given coordinates, where 0 and 1
+// correspond to 0 and 0xffff in a Channel but values beyond them are
+// allowed. FloatGray implements FloatChannel.
+type FloatChannel interface {
+	ImageReader
+	FloatAt(x, y int) float32
+}
+
+// FloatGray is an in-memory single-channel image of float32 values that are
+// not clamped, so it can hold intermediate results such as negative
+// gradients without losing precision. It implements both FloatChannel and
+// WritableChannel, clamping values to between 0 and 1 when read as colors.
+type FloatGray struct {
+	// Pix holds the image's values. The value of the pixel at (x, y) is at
+	// Pix[(y-Rect.Min.Y)*Stride+(x-Rect.Min.X)].
+	Pix []float32
+
+	// Stride is the Pix stride between vertically adjacent pixels.
+	Stride int
+
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewFloatGray returns a new FloatGray image with the given bounds.
+func NewFloatGray(r image.Rectangle) *FloatGray {
+	w, h := r.Dx(), r.Dy()
+	if w < 0 || h < 0 {
+		w, h = 0, 0
+	}
+	return &FloatGray{
+		Pix:    make([]float32, w*h),
+		Stride: w,
+		Rect:   r,
+	}
+}
+
+func (p *FloatGray) ColorModel() color.Model {
+	return color.Gray16Model
+}
+
+func (p *FloatGray) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *FloatGray) At(x, y int) color.Color {
+	return p.Gray16At(x, y)
+}
+
+// Gray16At returns the value of the pixel at the given coordinates, clamped
+// to between 0 and 1, as a color.Gray16.
+func (p *FloatGray) Gray16At(x, y int) color.Gray16 {
+	return color.Gray16{Y: floatToUint16(p.FloatAt(x, y))}
+}
+
+// FloatAt returns the value of the pixel at the given coordinates.
+func (p *FloatGray) FloatAt(x, y int) float32 {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return 0
+	}
+	return p.Pix[p.PixOffset(x, y)]
+}
+
+// PixOffset returns the index of the pixel at the given coordinates in Pix.
+func (p *FloatGray) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
+
+func (p *FloatGray) Set(x, y int, c color.Color) {
+	p.SetGray16(x, y, color.Gray16Model.Convert(c).(color.Gray16))
+}
+
+// SetGray16 sets the value of the pixel at the given coordinates to that of
+// a color.Gray16.
+func (p *FloatGray) SetGray16(x, y int, c color.Gray16) {
+	p.SetFloat(x, y, float32(c.Y)/math.MaxUint16)
+}
+
+// SetFloat sets the value of the pixel at the given coordinates.
+func (p *FloatGray) SetFloat(x, y int, v float32) {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = v
+}
+
+// SubImage returns an image representing the portion of the image visible
+// through r. The returned value shares pixels with the original image.
+func (p *FloatGray) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &FloatGray{}
+	}
+	return &FloatGray{
+		Pix:    p.Pix[p.PixOffset(r.Min.X, r.Min.Y):],
+		Stride: p.Stride,
+		Rect:   r,
+	}
+}
+
+// FloatPlanar is the float32 counterpart to Planar, holding red, green, blue
+// and alpha components, not premultiplied by alpha, in separate planes
+// without clamping them. Its colors are clamped when read as color.NRGBA64.
+type FloatPlanar struct {
+	// Planes holds the red, green, blue and alpha planes. Component i of the
+	// pixel at (x, y) is at Planes[i][(y-Rect.Min.Y)*Stride+(x-Rect.Min.X)].
+	Planes [4][]float32
+
+	// Stride is the plane stride between vertically adjacent pixels.
+	Stride int
+
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewFloatPlanar returns a new FloatPlanar image with the given bounds.
+func NewFloatPlanar(r image.Rectangle) *FloatPlanar {
+	w, h := r.Dx(), r.Dy()
+	if w < 0 || h < 0 {
+		w, h = 0, 0
+	}
+
+	p := &FloatPlanar{
+		Stride: w,
+		Rect:   r,
+	}
+	for i := range p.Planes {
+		p.Planes[i] = make([]float32, w*h)
+	}
+	return p
+}
+
+func (p *FloatPlanar) ColorModel() color.Model {
+	return color.NRGBA64Model
+}
+
+func (p *FloatPlanar) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *FloatPlanar) At(x, y int) color.Color {
+	return p.NRGBA64At(x, y)
+}
+
+// NRGBA64At returns the color of the pixel at the given coordinates, with
+// each component clamped to between 0 and 1.
+func (p *FloatPlanar) NRGBA64At(x, y int) color.NRGBA64 {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return color.NRGBA64{}
+	}
+
+	i := p.PlaneOffset(x, y)
+	return color.NRGBA64{
+		R: floatToUint16(p.Planes[0][i]),
+		G: floatToUint16(p.Planes[1][i]),
+		B: floatToUint16(p.Planes[2][i]),
+		A: floatToUint16(p.Planes[3][i]),
+	}
+}
+
+// PlaneOffset returns the index of the pixel at the given coordinates in each
+// of the planes.
+func (p *FloatPlanar) PlaneOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
+
+func (p *FloatPlanar) Set(x, y int, c color.Color) {
+	p.SetNRGBA64(x, y, color.NRGBA64Model.Convert(c).(color.NRGBA64))
+}
+
+// SetNRGBA64 sets the color of the pixel at the given coordinates.
+func (p *FloatPlanar) SetNRGBA64(x, y int, c color.NRGBA64) {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return
+	}
+
+	i := p.PlaneOffset(x, y)
+	p.Planes[0][i] = float32(c.R) / math.MaxUint16
+	p.Planes[1][i] = float32(c.G) / math.MaxUint16
+	p.Planes[2][i] = float32(c.B) / math.MaxUint16
+	p.Planes[3][i] = float32(c.A) / math.MaxUint16
+}
+
+// SubImage returns an image representing the portion of the image visible
+// through r. The returned value shares pixels with the original image.
+func (p *FloatPlanar) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &FloatPlanar{}
+	}
+
+	sub := &FloatPlanar{
+		Stride: p.Stride,
+		Rect:   r,
+	}
+	i := p.PlaneOffset(r.Min.X, r.Min.Y)
+	for j := range p.Planes {
+		sub.Planes[j] = p.Planes[j][i:]
+	}
+	return sub
+}
+
+// Channel returns a *FloatGray that shares plane i of the image, where 0 is
+// red, 1 green, 2 blue and 3 alpha.
+func (p *FloatPlanar) Channel(i int) *FloatGray {
+	return &FloatGray{
+		Pix:    p.Planes[i],
+		Stride: p.Stride,
+		Rect:   p.Rect,
+	}
+}
+
+// Channels returns red, green, blue and alpha *FloatGray images that share
+// the planes of the image.
+func (p *FloatPlanar) Channels() (r, g, b, a *FloatGray) {
+	return p.Channel(0), p.Channel(1), p.Channel(2), p.Channel(3)
+}
+
+// floatToUint16 scales a value from between 0 and 1 to between 0 and 0xffff,
+// clamping and rounding it.
+func floatToUint16(v float32) uint16 {
+	return clampUint16(float64(v) * math.MaxUint16)
+}
+
+// ChannelToFloat returns a *FloatGray by asserting the given Channel has that
+// type or, if it does not, concurrently scaling each of its values to between
+// 0 and 1.
+func ChannelToFloat(c Channel) *FloatGray {
+	if dst, ok := c.(*FloatGray); ok {
+		return dst
+	}
+
+	dst := NewFloatGray(c.Bounds())
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				dst.SetGray16(pt.X, pt.Y, c.Gray16At(pt.X, pt.Y))
+			},
+		),
+	)(dst.Rect)
+	return dst
+}
+
+// FloatToGray16 concurrently quantises a FloatChannel to an *image.Gray16,
+// clamping its values to between 0 and 1.
+func FloatToGray16(c FloatChannel) *image.Gray16 {
+	dst := image.NewGray16(c.Bounds())
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				dst.SetGray16(pt.X, pt.Y, color.Gray16{Y: floatToUint16(c.FloatAt(pt.X, pt.Y))})
+			},
+		),
+	)(dst.Rect)
+	return dst
+}
+
+// ConvertToFloatPlanar returns a *FloatPlanar instance by asserting the given
+// ImageReader has that type or, if it does not, concurrently converting the
+// color of each of its pixels.
+func ConvertToFloatPlanar(src ImageReader) *FloatPlanar {
+	switch src := src.(type) {
+	case *FloatPlanar:
+		return src
+	case *Planar:
+		dst := NewFloatPlanar(src.Rect)
+		QuickRP(
+			AllPointsRP(
+				func(pt image.Point) {
+					di, si := dst.PlaneOffset(pt.X, pt.Y), src.PlaneOffset(pt.X, pt.Y)
+					for i := range dst.Planes {
+						dst.Planes[i][di] = float32(src.Planes[i][si]) / math.MaxUint16
+					}
+				},
+			),
+		)(dst.Rect)
+		return dst
+	}
+
+	dst := NewFloatPlanar(src.Bounds())
+	Copy(dst, src)
+	return dst
+}
@@ -0,0 +1,86 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"testing"
+)
+
+func TestFloatConversions(t *testing.T) {
+	src := randomNRGBA64(copyTestRect).(*image.NRGBA64)
+
+	fp := ConvertToFloatPlanar(src)
+	if dst := ConvertToNRGBA64(fp); !bytes.Equal(dst.Pix, src.Pix) {
+		t.Error("FloatPlanar didn't convert back to the original image")
+	}
+	if dst := ConvertToFloatPlanar(ConvertToPlanar(src)); dst.Rect != fp.Rect || dst.Stride != fp.Stride {
+		t.Error("converting a Planar to FloatPlanar changed its layout")
+	} else {
+		for i := range dst.Planes {
+			for j := range dst.Planes[i] {
+				if dst.Planes[i][j] != fp.Planes[i][j] {
+					t.Fatalf("converting a Planar to FloatPlanar set plane %d to %v at %d, expected %v",
+						i, dst.Planes[i][j], j, fp.Planes[i][j])
+				}
+			}
+		}
+	}
+
+	gray := ConvertToGray16(src)
+	f := ChannelToFloat(gray)
+	if dst := FloatToGray16(f); !bytes.Equal(dst.Pix, gray.Pix) {
+		t.Error("FloatGray didn't convert back to the original channel")
+	}
+	if ChannelToFloat(f) != f {
+		t.Error("converting a FloatGray to FloatGray copied it")
+	}
+
+	// Values beyond 0 and 1 are kept but clamped when read as colors.
+	f.SetFloat(0, 10, -0.5)
+	f.SetFloat(1, 10, 1.5)
+	if f.FloatAt(0, 10) != -0.5 || f.FloatAt(1, 10) != 1.5 {
+		t.Error("FloatGray clamped its values")
+	}
+	if f.Gray16At(0, 10).Y != 0 || f.Gray16At(1, 10).Y != math.MaxUint16 {
+		t.Error("FloatGray didn't clamp its values when read as colors")
+	}
+}
+
+func TestFloatAverages(t *testing.T) {
+	gray := ConvertToGray16(randomNRGBA64(copyTestRect))
+	f := ChannelToFloat(gray)
+
+	for name, c := range map[string][2]Channel{
+		"row":    {RowAverageGray16(7, gray), RowAverageFloat(7, f)},
+		"column": {ColumnAverageGray16(7, gray), ColumnAverageFloat(7, f)},
+		"edges":  {EdgesGray16(7, gray), EdgesFloat(7, f)},
+	} {
+		if c[0].Bounds() != c[1].Bounds() {
+			t.Errorf("float %s average has bounds %v, expected %v", name, c[1].Bounds(), c[0].Bounds())
+		}
+
+		// The integer versions truncate, so differ by up to one.
+		AllPointsRP(
+			func(pt image.Point) {
+				i := float64(c[0].Gray16At(pt.X, pt.Y).Y)
+				v := float64(c[1].(*FloatGray).FloatAt(pt.X, pt.Y)) * math.MaxUint16
+				if math.Abs(v-i) > 1.01 {
+					t.Errorf("float %s average is %v at %v, expected about %v", name, v, pt, i)
+				}
+			},
+		)(c[0].Bounds())
+	}
+}
+
+func TestRowAverageFloatNegative(t *testing.T) {
+	f := NewFloatGray(image.Rect(0, 0, 4, 1))
+	copy(f.Pix, []float32{-1, -1, 1, 3})
+
+	avg := RowAverageFloat(2, f)
+	for x, expected := range []float32{-1, -1, 0, 2, 3} {
+		if v := avg.FloatAt(x-1, 0); v != expected {
+			t.Errorf("row average at %d is %v, expected %v", x-1, v, expected)
+		}
+	}
+}
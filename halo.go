@@ -0,0 +1,44 @@
+package imageutil
+
+import (
+	"image"
+)
+
+// HRP is a halo rectangle processor, any function that accepts an output
+// image.Rectangle and an input image.Rectangle containing it. Neighbourhood
+// operations should write only within out and read only within in.
+type HRP func(out, in image.Rectangle)
+
+// haloRP returns a RP that calls the given HRP with each rectangle it is
+// given and the same rectangle expanded by radius and clipped to bounds.
+func haloRP(radius int, bounds image.Rectangle, hrp HRP) RP {
+	return func(rect image.Rectangle) {
+		hrp(rect, rect.Inset(-radius).Intersect(bounds))
+	}
+}
+
+// HaloTilesRP returns a RP that devides an input rectangle into tiles as
+// TilesRP does and calls the given HRP with each tile as the output rectangle
+// and the tile expanded by radius, clipped to the input rectangle, as the
+// input rectangle.
+func HaloTilesRP(width, height, radius int, hrp HRP) RP {
+	if radius < 0 {
+		return noopRP
+	}
+
+	return func(rect image.Rectangle) {
+		TilesRP(width, height, haloRP(radius, rect, hrp))(rect)
+	}
+}
+
+// QuickHaloTilesRP is the concurrent counterpart to HaloTilesRP, processing
+// tiles as QuickTilesRP does.
+func QuickHaloTilesRP(width, height, radius int, hrp HRP) RP {
+	if radius < 0 {
+		return noopRP
+	}
+
+	return func(rect image.Rectangle) {
+		QuickTilesRP(width, height, haloRP(radius, rect, hrp))(rect)
+	}
+}
@@ -0,0 +1,61 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestHaloTilesRP(t *testing.T) {
+	rect := image.Rect(0, 0, 100, 50)
+
+	HaloTilesRP(16, 16, 3, func(out, in image.Rectangle) {
+		if !out.In(in) || !in.In(rect) {
+			t.Errorf("unexpected halo %v for tile %v", in, out)
+		}
+
+		if expected := out.Inset(-3).Intersect(rect); in != expected {
+			t.Errorf("expected halo %v for tile %v, found %v", expected, out, in)
+		}
+	})(rect)
+
+	HaloTilesRP(16, 16, -1, func(out, in image.Rectangle) {
+		t.Error("specifying a negative radius did not result in a noop")
+	})(rect)
+}
+
+func TestQuickHaloTilesRP(t *testing.T) {
+	src := ConvertToGray16(randomNRGBA64(image.Rect(0, 0, 200, 150)))
+	bounds := src.Bounds()
+
+	// Compute a 3x3 maximum tile-locally, copying each halo before reading
+	// from it to ensure no pixels outside of it are used.
+	dst := image.NewGray16(bounds)
+	QuickHaloTilesRP(32, 32, 1, func(out, in image.Rectangle) {
+		halo := image.NewGray16(in)
+		Copy(halo, src.SubImage(in).(*image.Gray16))
+
+		AllPointsRP(func(pt image.Point) {
+			var m uint16
+			AllPointsRP(func(q image.Point) {
+				if y := halo.Gray16At(q.X, q.Y).Y; y > m {
+					m = y
+				}
+			})(image.Rect(pt.X-1, pt.Y-1, pt.X+2, pt.Y+2).Intersect(in))
+			dst.SetGray16(pt.X, pt.Y, color.Gray16{Y: m})
+		})(out)
+	})(bounds)
+
+	AllPointsRP(func(pt image.Point) {
+		var m uint16
+		AllPointsRP(func(q image.Point) {
+			if y := src.Gray16At(q.X, q.Y).Y; y > m {
+				m = y
+			}
+		})(image.Rect(pt.X-1, pt.Y-1, pt.X+2, pt.Y+2).Intersect(bounds))
+
+		if y := dst.Gray16At(pt.X, pt.Y).Y; y != m {
+			t.Fatalf("expected %d, found %d at %v", m, y, pt)
+		}
+	})(bounds)
+}
@@ -27,14 +27,115 @@ type ImageReadWriter interface {
 // Copy concurrently copies Color values from a source ImageReader to a
 // destination ImageReadWriter.
 func Copy(dst ImageReadWriter, src ImageReader) {
-	if dst != src {
-		QuickRP(
+	copyImage(DefaultScheduler, dst, src)
+}
+
+// CopyProgress is Copy, reporting its progress to the given Progress.
+func CopyProgress(dst ImageReadWriter, src ImageReader, p Progress) {
+	copyImage(DefaultScheduler.WithProgress(p), dst, src)
+}
+
+// copyImage implements Copy using the given Scheduler. Images of the same
+// bounds whose concrete types are a known pair are copied directly between
+// their Pix slices.
+func copyImage(s *Scheduler, dst ImageReadWriter, src ImageReader) {
+	if dst == src {
+		return
+	}
+
+	if bounds := dst.Bounds(); bounds == src.Bounds() {
+		if c := fastCopier(dst, src); c != nil {
+			s.RP(
+				func(rect image.Rectangle) {
+					c(rect, rect.Min)
+				},
+			)(bounds)
+			return
+		}
+	}
+
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				dst.Set(pt.X, pt.Y, src.At(pt.X, pt.Y))
+			},
+		),
+	)(dst.Bounds().Union(src.Bounds()))
+}
+
+// CopyRect concurrently copies the Color values within srcRect of a source
+// ImageReader to a destination ImageReadWriter, such that srcRect.Min lands
+// on dstPt. As with draw.Draw, the rectangle copied is clipped to the bounds
+// of both images, and no other destination pixels are changed. The source
+// and destination may be the same image, or views of the same pixels such as
+// an image and a SubImage of it, in which case overlapping rectangles are
+// copied as if through an intermediate buffer. As with draw.Draw, other
+// sources that share memory with the destination aren't supported.
+func CopyRect(dst ImageReadWriter, dstPt image.Point, src ImageReader, srcRect image.Rectangle) {
+	copyRect(DefaultScheduler, dst, dstPt, src, srcRect)
+}
+
+// copyRect implements CopyRect using the given Scheduler.
+func copyRect(s *Scheduler, dst ImageReadWriter, dstPt image.Point, src ImageReader, srcRect image.Rectangle) {
+
+	// Clip the destination rectangle to the bounds of both images, where
+	// delta translates destination coordinates to source coordinates.
+	delta := srcRect.Min.Sub(dstPt)
+	r := image.Rectangle{Min: dstPt, Max: dstPt.Add(srcRect.Size())}
+	r = r.Intersect(dst.Bounds()).Intersect(src.Bounds().Sub(delta))
+	same := samePixels(dst, src)
+	if r.Empty() || (same && delta == image.Point{}) {
+		return
+	}
+
+	c := fastCopier(dst, src)
+	if c == nil {
+		c = func(r image.Rectangle, sp image.Point) {
+			d := sp.Sub(r.Min)
 			AllPointsRP(
 				func(pt image.Point) {
-					dst.Set(pt.X, pt.Y, src.At(pt.X, pt.Y))
+					dst.Set(pt.X, pt.Y, src.At(pt.X+d.X, pt.Y+d.Y))
 				},
-			),
-		)(dst.Bounds().Union(src.Bounds()))
+			)(r)
+		}
+	}
+
+	if same && r.Overlaps(r.Add(delta)) {
+		copyOverlapping(c, r, delta)
+		return
+	}
+
+	s.RP(
+		func(rect image.Rectangle) {
+			c(rect, rect.Min.Add(delta))
+		},
+	)(r)
+}
+
+// copyOverlapping sequentially copies pixels within a single image, or views
+// of the same pixels, from r.Add(delta) to r, a row at a time or, for
+// horizontal copies, a pixel at a time, in an order that reads every pixel
+// before it is overwritten.
+func copyOverlapping(c pixCopier, r image.Rectangle, delta image.Point) {
+	y0, y1, dy := r.Min.Y, r.Max.Y, 1
+	if delta.Y < 0 {
+		y0, y1, dy = r.Max.Y-1, r.Min.Y-1, -1
+	}
+
+	for y := y0; y != y1; y += dy {
+		if delta.Y != 0 {
+			c(image.Rect(r.Min.X, y, r.Max.X, y+1), image.Pt(r.Min.X, y).Add(delta))
+			continue
+		}
+
+		x0, x1, dx := r.Min.X, r.Max.X, 1
+		if delta.X < 0 {
+			x0, x1, dx = r.Max.X-1, r.Min.X-1, -1
+		}
+
+		for x := x0; x != x1; x += dx {
+			c(image.Rect(x, y, x+1, y+1), image.Pt(x, y).Add(delta))
+		}
 	}
 }
 
@@ -141,3 +242,93 @@ func ConvertToGray16(src ImageReader) *image.Gray16 {
 	Copy(dst, src)
 	return dst
 }
+
+// ConvertToCMYK returns an *image.CMYK instance by asserting the given
+// ImageReader has that type or, if it does not, using Copy to concurrently
+// set the color.Color values of a new *image.CMYK instance with the same
+// bounds.
+func ConvertToCMYK(src ImageReader) *image.CMYK {
+	if dst, ok := src.(*image.CMYK); ok {
+		return dst
+	}
+	dst := image.NewCMYK(src.Bounds())
+	Copy(dst, src)
+	return dst
+}
+
+// ConvertToPaletted returns an *image.Paletted instance with the given
+// palette by asserting the given ImageReader has that type and palette or, if
+// it does not, using Copy to concurrently set each pixel of a new
+// *image.Paletted instance with the same bounds to the closest color in the
+// palette.
+func ConvertToPaletted(src ImageReader, p color.Palette) *image.Paletted {
+	if dst, ok := src.(*image.Paletted); ok && samePalette(dst.Palette, p) {
+		return dst
+	}
+	dst := image.NewPaletted(src.Bounds(), p)
+	Copy(dst, src)
+	return dst
+}
+
+// ConvertTo returns an ImageReadWriter of the concrete type that natively
+// stores colors of the given color.Model, using the ConvertTo function for
+// that type. A color.Palette model produces an *image.Paletted, and the YCbCr
+// models keep the chroma subsampling ratio of an *image.YCbCr or
+// *image.NYCbCrA source, defaulting to 4:4:4. Any other model produces an
+// *image.NRGBA64 holding colors converted by the model.
+func ConvertTo(src ImageReader, model color.Model) ImageReadWriter {
+	if p, ok := model.(color.Palette); ok {
+		return ConvertToPaletted(src, p)
+	}
+
+	switch model {
+	case color.RGBAModel:
+		return ConvertToRGBA(src)
+	case color.RGBA64Model:
+		return ConvertToRGBA64(src)
+	case color.NRGBAModel:
+		return ConvertToNRGBA(src)
+	case color.NRGBA64Model:
+		return ConvertToNRGBA64(src)
+	case color.AlphaModel:
+		return ConvertToAlpha(src)
+	case color.Alpha16Model:
+		return ConvertToAlpha16(src)
+	case color.GrayModel:
+		return ConvertToGray(src)
+	case color.Gray16Model:
+		return ConvertToGray16(src)
+	case color.CMYKModel:
+		return ConvertToCMYK(src)
+	case color.YCbCrModel:
+		return ConvertToYCbCr(src, subsampleRatio(src))
+	case color.NYCbCrAModel:
+		return ConvertToNYCbCrA(src, subsampleRatio(src))
+	}
+
+	dst := image.NewNRGBA64(src.Bounds())
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				dst.Set(pt.X, pt.Y, model.Convert(src.At(pt.X, pt.Y)))
+			},
+		),
+	)(dst.Bounds())
+	return dst
+}
+
+// subsampleRatio returns the chroma subsampling ratio of an ImageReader with
+// one, and 4:4:4 otherwise.
+func subsampleRatio(img ImageReader) image.YCbCrSubsampleRatio {
+	switch img := img.(type) {
+	case *image.YCbCr:
+		return img.SubsampleRatio
+	case *YCbCr:
+		return img.SubsampleRatio
+	case *image.NYCbCrA:
+		return img.SubsampleRatio
+	case *NYCbCrA:
+		return img.SubsampleRatio
+	}
+	return image.YCbCrSubsampleRatio444
+}
@@ -3,6 +3,7 @@ package imageutil
 import (
 	"image"
 	"image/color"
+	"image/color/palette"
 	"image/draw"
 	"math"
 	"math/rand"
@@ -122,3 +123,47 @@ func BenchmarkConvertToGray16(b *testing.B) {
 		ConvertToGray16(src)
 	}
 }
+
+func TestConvertTo(t *testing.T) {
+	src := randomNRGBA64(image.Rect(0, 0, 20, 20))
+
+	for _, model := range []color.Model{
+		color.RGBAModel,
+		color.RGBA64Model,
+		color.NRGBAModel,
+		color.NRGBA64Model,
+		color.AlphaModel,
+		color.Alpha16Model,
+		color.GrayModel,
+		color.Gray16Model,
+		color.CMYKModel,
+		color.YCbCrModel,
+		color.NYCbCrAModel,
+		color.Palette(palette.WebSafe),
+	} {
+		dst := ConvertTo(src, model)
+		if _, ok := model.(color.Palette); ok {
+			if _, ok := dst.(*image.Paletted); !ok {
+				t.Errorf("converting to a palette produced a %T", dst)
+			}
+		} else if dst.ColorModel() != model {
+			t.Errorf("converting to %T produced an image with model %T", model, dst.ColorModel())
+		}
+
+		AllPointsRP(func(pt image.Point) {
+			expected := model.Convert(src.At(pt.X, pt.Y))
+			if c := dst.At(pt.X, pt.Y); c != expected {
+				t.Fatalf("converting to %T: expected %v, found %v at %v", model, expected, c, pt)
+			}
+		})(src.Bounds())
+	}
+
+	if ConvertTo(src, color.NRGBA64Model) != src {
+		t.Error("converting to the source model did not return the source")
+	}
+
+	ycbcr := image.NewYCbCr(src.Bounds(), image.YCbCrSubsampleRatio420)
+	if dst := ConvertTo(ycbcr, color.YCbCrModel).(*YCbCr); dst.YCbCr != ycbcr {
+		t.Error("converting a YCbCr source to the YCbCr model did not wrap the source")
+	}
+}
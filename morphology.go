@@ -0,0 +1,330 @@
+package imageutil
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// StructuringElement is the neighbourhood of each pixel that a morphological
+// operation considers. The pixel at column x and row y of the element is
+// included if Mask[y*Width+x] is set, and the element is centred on the
+// pixel at column Width/2 and row Height/2.
+type StructuringElement struct {
+	Width, Height int
+	Mask          []bool
+}
+
+// NewStructuringElement returns a StructuringElement with the given rows of
+// its mask, which must all have the same length.
+func NewStructuringElement(rows [][]bool) StructuringElement {
+	e := StructuringElement{Height: len(rows)}
+	if len(rows) > 0 {
+		e.Width = len(rows[0])
+	}
+	e.Mask = make([]bool, 0, e.Width*e.Height)
+	for y, row := range rows {
+		if len(row) != e.Width {
+			panic(fmt.Sprintf("imageutil: structuring element row %d has %d pixels, expected %d", y, len(row), e.Width))
+		}
+		e.Mask = append(e.Mask, row...)
+	}
+	return e
+}
+
+// RectElement returns a rectangular StructuringElement with the given width
+// and height.
+func RectElement(width, height int) StructuringElement {
+	e := StructuringElement{Width: width, Height: height, Mask: make([]bool, width*height)}
+	for i := range e.Mask {
+		e.Mask[i] = true
+	}
+	return e
+}
+
+// CrossElement returns a StructuringElement of the pixels within the given
+// radius of the centre horizontally or vertically.
+func CrossElement(radius int) StructuringElement {
+	size := 2*radius + 1
+	e := StructuringElement{Width: size, Height: size, Mask: make([]bool, size*size)}
+	for i := 0; i < size; i++ {
+		e.Mask[radius*size+i] = true
+		e.Mask[i*size+radius] = true
+	}
+	return e
+}
+
+// DiskElement returns a StructuringElement of the pixels within the given
+// radius of the centre, the same as a WindowCircle.
+func DiskElement(radius int) StructuringElement {
+	size := 2*radius + 1
+	e := StructuringElement{Width: size, Height: size, Mask: make([]bool, size*size)}
+	for y, w := range WindowCircle.halfWidths(radius) {
+		for x := radius - w; x <= radius+w; x++ {
+			e.Mask[y*size+x] = true
+		}
+	}
+	return e
+}
+
+// isRect returns whether the element includes every pixel of a non-empty
+// rectangle.
+func (e StructuringElement) isRect() bool {
+	for _, m := range e.Mask {
+		if !m {
+			return false
+		}
+	}
+	return len(e.Mask) > 0
+}
+
+// elementRun is a horizontal run of pixels of a StructuringElement, offset
+// from its centre.
+type elementRun struct {
+	dx, dy, length int
+}
+
+// runs returns the horizontal runs of pixels of the element, reflected
+// through its centre if reflect is set.
+func (e StructuringElement) runs(reflect bool) []elementRun {
+	var runs []elementRun
+	for y := 0; y < e.Height; y++ {
+		for x := 0; x < e.Width; {
+			if !e.Mask[y*e.Width+x] {
+				x++
+				continue
+			}
+			start := x
+			for x < e.Width && e.Mask[y*e.Width+x] {
+				x++
+			}
+
+			r := elementRun{dx: start - e.Width/2, dy: y - e.Height/2, length: x - start}
+			if reflect {
+				r.dx, r.dy = -r.dx-r.length+1, -r.dy
+			}
+			runs = append(runs, r)
+		}
+	}
+	return runs
+}
+
+// morphIdentity returns the value that doesn't change the result of a
+// dilation, if dilate is set, or an erosion.
+func morphIdentity(dilate bool) uint16 {
+	if dilate {
+		return 0
+	}
+	return math.MaxUint16
+}
+
+// morphLineWindows sets each dst[j] to the maximum, if dilate is set, or the
+// minimum of the values of src in the window of length k ending at src[j],
+// for j up to len(src)+k-1, counting values outside src as the identity. It
+// uses the van Herk/Gil-Werman algorithm, dividing the padded values into
+// blocks of length k and combining running maxima or minima from the start
+// and end of each block, for three comparisons per value regardless of k.
+// The buffers p, g and h must have a length of at least len(src)+2k-2.
+func morphLineWindows(dst, src []uint16, k int, dilate bool, p, g, h []uint16) {
+	op := func(a, b uint16) uint16 {
+		if (a > b) == dilate {
+			return a
+		}
+		return b
+	}
+
+	m := len(src) + 2*k - 2
+	p, g, h = p[:m], g[:m], h[:m]
+	identity := morphIdentity(dilate)
+	for i := range p {
+		p[i] = identity
+	}
+	copy(p[k-1:], src)
+
+	for i := range p {
+		if i%k == 0 {
+			g[i] = p[i]
+		} else {
+			g[i] = op(g[i-1], p[i])
+		}
+	}
+	for i := m - 1; i >= 0; i-- {
+		if i%k == k-1 || i == m-1 {
+			h[i] = p[i]
+		} else {
+			h[i] = op(h[i+1], p[i])
+		}
+	}
+
+	for j := range dst[:len(src)+k-1] {
+		dst[j] = op(h[j], g[j+k-1])
+	}
+}
+
+// morphLine concurrently takes the maximum, if dilate is set, or the minimum
+// of each window of k values of a Channel along its rows, or columns if
+// vertical is set, returning them as an *image.Gray16 whose value at each
+// coordinate is that of the window starting there. Like RowAverageGray16, the
+// result's bounds are extended back by k-1 to include all the windows that
+// overlap the bounds.
+func morphLine(s *Scheduler, k int, dilate, vertical bool, img Channel) *image.Gray16 {
+	bounds := img.Bounds()
+	resultBounds := image.Rect(bounds.Min.X-k+1, bounds.Min.Y, bounds.Max.X, bounds.Max.Y)
+	n := bounds.Dx()
+	lines := s.RowsRP
+	if vertical {
+		resultBounds = image.Rect(bounds.Min.X, bounds.Min.Y-k+1, bounds.Max.X, bounds.Max.Y)
+		n = bounds.Dy()
+		lines = s.ColumnsRP
+	}
+	resultImg := image.NewGray16(resultBounds)
+
+	lines(func(rect image.Rectangle) {
+		m := n + 2*k - 2
+		src, dst := make([]uint16, n), make([]uint16, n+k-1)
+		p, g, h := make([]uint16, m), make([]uint16, m), make([]uint16, m)
+
+		if vertical {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				for i := range src {
+					src[i] = img.Gray16At(x, bounds.Min.Y+i).Y
+				}
+				morphLineWindows(dst, src, k, dilate, p, g, h)
+				for i, v := range dst {
+					resultImg.SetGray16(x, resultBounds.Min.Y+i, color.Gray16{Y: v})
+				}
+			}
+		} else {
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				for i := range src {
+					src[i] = img.Gray16At(bounds.Min.X+i, y).Y
+				}
+				morphLineWindows(dst, src, k, dilate, p, g, h)
+				for i, v := range dst {
+					resultImg.SetGray16(resultBounds.Min.X+i, y, color.Gray16{Y: v})
+				}
+			}
+		}
+	})(bounds)
+
+	return resultImg
+}
+
+// DilateGray16 concurrently replaces each value of a Channel with the maximum
+// of the values under a StructuringElement reflected through its centre and
+// centred on it, returning an *image.Gray16 with the same bounds. Values
+// beyond the bounds are ignored. Rectangular elements are applied as separate
+// horizontal and vertical passes, and other elements as a pass for each of
+// their horizontal runs of pixels, so that the cost per pixel is independent
+// of the element's width. For binary channels of 0 and 0xffff, this is binary
+// dilation.
+func DilateGray16(e StructuringElement, img Channel) *image.Gray16 {
+	return morphGray16(DefaultScheduler, e, true, img)
+}
+
+// ErodeGray16 is DilateGray16 with the minimum of the values under the
+// StructuringElement, not reflected, centred on each value.
+func ErodeGray16(e StructuringElement, img Channel) *image.Gray16 {
+	return morphGray16(DefaultScheduler, e, false, img)
+}
+
+// morphGray16 implements DilateGray16, if dilate is set, and ErodeGray16
+// using the given Scheduler.
+func morphGray16(s *Scheduler, e StructuringElement, dilate bool, img Channel) *image.Gray16 {
+	bounds := img.Bounds()
+
+	// The windows of morphLine start at each coordinate, so shift them back to
+	// the start of the element's pixels.
+	if e.isRect() {
+		dx, dy := e.Width/2, e.Height/2
+		if dilate {
+			dx, dy = e.Width-1-dx, e.Height-1-dy
+		}
+
+		rows := morphLine(s, e.Width, dilate, false, img)
+		rows.Rect = rows.Rect.Add(image.Pt(dx, 0))
+		rows = rows.SubImage(bounds).(*image.Gray16)
+
+		columns := morphLine(s, e.Height, dilate, true, rows)
+		columns.Rect = columns.Rect.Add(image.Pt(0, dy))
+		return columns.SubImage(bounds).(*image.Gray16)
+	}
+
+	runs := e.runs(dilate)
+	windows := make(map[int]*image.Gray16)
+	for _, r := range runs {
+		if windows[r.length] == nil {
+			windows[r.length] = morphLine(s, r.length, dilate, false, img)
+		}
+	}
+
+	dst := image.NewGray16(bounds)
+	identity := morphIdentity(dilate)
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				v := identity
+				for _, r := range runs {
+					p := image.Pt(pt.X+r.dx, pt.Y+r.dy)
+					w := windows[r.length]
+					if !p.In(w.Rect) {
+						continue
+					}
+					if u := w.Gray16At(p.X, p.Y).Y; dilate && u > v || !dilate && u < v {
+						v = u
+					}
+				}
+				dst.SetGray16(pt.X, pt.Y, color.Gray16{Y: v})
+			},
+		),
+	)(bounds)
+	return dst
+}
+
+// OpenGray16 is ErodeGray16 followed by DilateGray16 with the same
+// StructuringElement, which removes bright details smaller than the element.
+func OpenGray16(e StructuringElement, img Channel) *image.Gray16 {
+	return morphGray16(DefaultScheduler, e, true, morphGray16(DefaultScheduler, e, false, img))
+}
+
+// CloseGray16 is DilateGray16 followed by ErodeGray16 with the same
+// StructuringElement, which fills dark details smaller than the element.
+func CloseGray16(e StructuringElement, img Channel) *image.Gray16 {
+	return morphGray16(DefaultScheduler, e, false, morphGray16(DefaultScheduler, e, true, img))
+}
+
+// TopHatGray16 returns the difference between a Channel and OpenGray16 of it,
+// which keeps the bright details smaller than the StructuringElement.
+func TopHatGray16(e StructuringElement, img Channel) *image.Gray16 {
+	return morphDifference(img, OpenGray16(e, img))
+}
+
+// BlackHatGray16 returns the difference between CloseGray16 of a Channel and
+// the channel, which keeps the dark details smaller than the
+// StructuringElement.
+func BlackHatGray16(e StructuringElement, img Channel) *image.Gray16 {
+	return morphDifference(CloseGray16(e, img), img)
+}
+
+// MorphologicalGradientGray16 returns the difference between DilateGray16 and
+// ErodeGray16 of a Channel, which is large at its edges.
+func MorphologicalGradientGray16(e StructuringElement, img Channel) *image.Gray16 {
+	return morphDifference(DilateGray16(e, img), ErodeGray16(e, img))
+}
+
+// morphDifference concurrently subtracts the values of one Channel from those
+// of another with the same bounds, clamping the results at zero.
+func morphDifference(a, b Channel) *image.Gray16 {
+	dst := image.NewGray16(a.Bounds())
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				if u, v := a.Gray16At(pt.X, pt.Y).Y, b.Gray16At(pt.X, pt.Y).Y; u > v {
+					dst.SetGray16(pt.X, pt.Y, color.Gray16{Y: u - v})
+				}
+			},
+		),
+	)(dst.Rect)
+	return dst
+}
@@ -0,0 +1,150 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// morphReference returns the maximum, if dilate is set, or the minimum of the
+// values of a Channel under a StructuringElement at a point by checking each
+// of its pixels.
+func morphReference(img Channel, e StructuringElement, dilate bool, pt image.Point) uint16 {
+	v := morphIdentity(dilate)
+	for y := 0; y < e.Height; y++ {
+		for x := 0; x < e.Width; x++ {
+			if !e.Mask[y*e.Width+x] {
+				continue
+			}
+			d := image.Pt(x-e.Width/2, y-e.Height/2)
+			if dilate {
+				d = d.Mul(-1)
+			}
+			if p := pt.Add(d); p.In(img.Bounds()) {
+				u := img.Gray16At(p.X, p.Y).Y
+				if dilate && u > v || !dilate && u < v {
+					v = u
+				}
+			}
+		}
+	}
+	return v
+}
+
+func TestMorphGray16(t *testing.T) {
+	img := ConvertToGray16(randomNRGBA64(image.Rect(-3, 5, 37, 38)))
+	elements := map[string]StructuringElement{
+		"point":  RectElement(1, 1),
+		"square": RectElement(5, 5),
+		"even":   RectElement(4, 7),
+		"wide":   RectElement(60, 2),
+		"cross":  CrossElement(2),
+		"disk":   DiskElement(4),
+		"custom": NewStructuringElement([][]bool{
+			{true, false, true, true},
+			{false, false, false, true},
+		}),
+	}
+
+	for name, e := range elements {
+		for _, dilate := range []bool{false, true} {
+			var dst *image.Gray16
+			if dilate {
+				dst = DilateGray16(e, img)
+			} else {
+				dst = ErodeGray16(e, img)
+			}
+			if dst.Bounds() != img.Bounds() {
+				t.Errorf("%s result has bounds %v, expected %v", name, dst.Bounds(), img.Bounds())
+			}
+
+			AllPointsRP(
+				func(pt image.Point) {
+					expected := morphReference(img, e, dilate, pt)
+					if v := dst.Gray16At(pt.X, pt.Y).Y; v != expected {
+						t.Fatalf("%s result with dilate %v is %#04x at %v, expected %#04x", name, dilate, v, pt, expected)
+					}
+				},
+			)(img.Rect)
+		}
+	}
+}
+
+func TestMorphOperations(t *testing.T) {
+	img := ConvertToGray16(randomNRGBA64(image.Rect(-3, 5, 37, 38)))
+	e := NewStructuringElement([][]bool{
+		{true, true, false},
+		{false, true, true},
+	})
+
+	open, closed := OpenGray16(e, img), CloseGray16(e, img)
+	AllPointsRP(
+		func(pt image.Point) {
+			o, v, c := open.Gray16At(pt.X, pt.Y).Y, img.Gray16At(pt.X, pt.Y).Y, closed.Gray16At(pt.X, pt.Y).Y
+			if o > v || v > c {
+				t.Errorf("opening, value and closing at %v are %#04x, %#04x and %#04x", pt, o, v, c)
+			}
+		},
+	)(img.Rect)
+
+	// Opening and closing are idempotent.
+	if again := OpenGray16(e, open); !bytes.Equal(pix(again), pix(open)) {
+		t.Error("opening an opened channel changed it")
+	}
+	if again := CloseGray16(e, closed); !bytes.Equal(pix(again), pix(closed)) {
+		t.Error("closing a closed channel changed it")
+	}
+
+	topHat, blackHat := TopHatGray16(e, img), BlackHatGray16(e, img)
+	gradient := MorphologicalGradientGray16(e, img)
+	dilated, eroded := DilateGray16(e, img), ErodeGray16(e, img)
+	AllPointsRP(
+		func(pt image.Point) {
+			v := img.Gray16At(pt.X, pt.Y).Y
+			if th := topHat.Gray16At(pt.X, pt.Y).Y; th != v-open.Gray16At(pt.X, pt.Y).Y {
+				t.Errorf("top-hat is %#04x at %v", th, pt)
+			}
+			if bh := blackHat.Gray16At(pt.X, pt.Y).Y; bh != closed.Gray16At(pt.X, pt.Y).Y-v {
+				t.Errorf("black-hat is %#04x at %v", bh, pt)
+			}
+			if g := gradient.Gray16At(pt.X, pt.Y).Y; g != dilated.Gray16At(pt.X, pt.Y).Y-eroded.Gray16At(pt.X, pt.Y).Y {
+				t.Errorf("gradient is %#04x at %v", g, pt)
+			}
+		},
+	)(img.Rect)
+}
+
+func TestBinaryMorphology(t *testing.T) {
+	rect := image.Rect(0, 0, 21, 21)
+	img := image.NewGray16(rect)
+	img.SetGray16(10, 10, color.Gray16{Y: math.MaxUint16})
+
+	// Dilating a point gives the element, and eroding that gives the point.
+	e := DiskElement(5)
+	dilated := DilateGray16(e, img)
+	AllPointsRP(
+		func(pt image.Point) {
+			d := pt.Sub(image.Pt(10, 10))
+			var expected uint16
+			if d.X*d.X+d.Y*d.Y <= 25 {
+				expected = math.MaxUint16
+			}
+			if v := dilated.Gray16At(pt.X, pt.Y).Y; v != expected {
+				t.Errorf("dilated point is %#04x at %v, expected %#04x", v, pt, expected)
+			}
+		},
+	)(rect)
+	if eroded := ErodeGray16(e, dilated); !bytes.Equal(eroded.Pix, img.Pix) {
+		t.Error("eroding a dilated point didn't give the point")
+	}
+}
+
+func BenchmarkDilateGray16(b *testing.B) {
+	gray := ConvertToGray16(randomNRGBA64(image.Rect(0, 0, 256, 256)))
+	e := RectElement(31, 31)
+	for i := 0; i < b.N; i++ {
+		DilateGray16(e, gray)
+	}
+}
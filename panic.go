@@ -0,0 +1,72 @@
+package imageutil
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is a panic recovered while processing a rectangle, along with
+// the rectangle and the stack of the Go routine that panicked.
+type PanicError struct {
+	Rect  image.Rectangle
+	Value interface{}
+	Stack []byte
+}
+
+// newPanicError returns a PanicError for the given recovered value. It must be
+// called from the deferred function that recovered the value.
+func newPanicError(rect image.Rectangle, value interface{}) *PanicError {
+	return &PanicError{
+		Rect:  rect,
+		Value: value,
+		Stack: debug.Stack(),
+	}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("imageutil: panic processing %v: %v\n\n%s", e.Rect, e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// RecoverRP returns an ERP that calls the given RP on an input rectangle,
+// recovering any panic and returning it as a *PanicError.
+func RecoverRP(rp RP) ERP {
+	return func(rect image.Rectangle) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = newPanicError(rect, r)
+			}
+		}()
+
+		rp(rect)
+		return nil
+	}
+}
+
+// SafeConcurrentRP is ConcurrentRP for RPs that may panic. Any panic is
+// recovered within its Go routine and added to errs as a *PanicError, after
+// which no further Go routines are started. Once the WaitGroup completes the
+// calling Go routine can return errs.Err() or pass it to Repanic.
+func SafeConcurrentRP(w *sync.WaitGroup, errs *Errors, rp RP) RP {
+	erp := ConcurrentERP(w, errs, RecoverRP(rp))
+	return func(rect image.Rectangle) {
+		erp(rect)
+	}
+}
+
+// Repanic panics with the first *PanicError found in err, if there is one,
+// re-raising a panic recovered from another Go routine on the calling one.
+func Repanic(err error) {
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		panic(pe)
+	}
+}
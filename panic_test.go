@@ -0,0 +1,96 @@
+package imageutil
+
+import (
+	"errors"
+	"image"
+	"sync"
+	"testing"
+)
+
+func TestRecoverRP(t *testing.T) {
+	rect := image.Rect(1, 2, 3, 4)
+	err := RecoverRP(func(image.Rectangle) {
+		panic("test panic")
+	})(rect)
+
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatal("expected a *PanicError, found", err)
+	}
+
+	if pe.Rect != rect || pe.Value != "test panic" || len(pe.Stack) == 0 {
+		t.Error("unexpected *PanicError:", pe)
+	}
+
+	if err := RecoverRP(func(image.Rectangle) {})(rect); err != nil {
+		t.Error("expected no error, found", err)
+	}
+}
+
+func TestSafeConcurrentRP(t *testing.T) {
+	var (
+		w    sync.WaitGroup
+		errs Errors
+	)
+
+	testErr := errors.New("test error")
+	rp := SafeConcurrentRP(&w, &errs, func(rect image.Rectangle) {
+		if rect.Min.X == 3 {
+			panic(testErr)
+		}
+	})
+
+	for x := 0; x < 10; x++ {
+		rp(image.Rect(x, 0, x+1, 1))
+	}
+	w.Wait()
+
+	err := errs.Err()
+	if !errors.Is(err, testErr) {
+		t.Fatal("expected the panic value to be returned, found", err)
+	}
+
+	defer func() {
+		if r, ok := recover().(*PanicError); !ok || r.Rect != image.Rect(3, 0, 4, 1) {
+			t.Error("expected a *PanicError to be re-raised, found", r)
+		}
+	}()
+	Repanic(err)
+}
+
+func TestSchedulerPanic(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		func() {
+			defer func() {
+				r, ok := recover().(*PanicError)
+				if !ok || r.Value != "test panic" || r.Rect != image.Rect(5, 5, 6, 6) || len(r.Stack) == 0 {
+					t.Errorf("expected a *PanicError to be re-raised with %d workers, found %v", workers, r)
+				}
+			}()
+
+			NewScheduler(workers, image.Pt(1, 1)).RP(func(rect image.Rectangle) {
+				if rect.Min == image.Pt(5, 5) {
+					panic("test panic")
+				}
+			})(image.Rect(0, 0, 10, 10))
+
+			t.Errorf("expected a panic with %d workers", workers)
+		}()
+	}
+
+	// A *PanicError from a nested Scheduler isn't wrapped again.
+	defer func() {
+		if r, ok := recover().(*PanicError); !ok || r.Value != "test panic" {
+			t.Error("expected the nested *PanicError to be re-raised, found", r)
+		}
+	}()
+
+	inner := NewScheduler(1, image.Pt(1, 1))
+	NewScheduler(1, image.Pt(10, 10)).RP(func(rect image.Rectangle) {
+		inner.RP(func(image.Rectangle) {
+			panic("test panic")
+		})(rect)
+	})(image.Rect(0, 0, 10, 10))
+
+	t.Error("expected a panic")
+}
@@ -0,0 +1,321 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Planar is an in-memory image whose red, green, blue and alpha components,
+// not premultiplied by alpha, are each stored in a separate plane of uint16
+// values. Filters that run several times over the same channel can read it
+// from its plane without converting colors.
+type Planar struct {
+	// Planes holds the red, green, blue and alpha planes. Component i of the
+	// pixel at (x, y) is at Planes[i][(y-Rect.Min.Y)*Stride+(x-Rect.Min.X)].
+	Planes [4][]uint16
+
+	// Stride is the plane stride between vertically adjacent pixels.
+	Stride int
+
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewPlanar returns a new Planar image with the given bounds.
+func NewPlanar(r image.Rectangle) *Planar {
+	w, h := r.Dx(), r.Dy()
+	if w < 0 || h < 0 {
+		w, h = 0, 0
+	}
+
+	p := &Planar{
+		Stride: w,
+		Rect:   r,
+	}
+	for i := range p.Planes {
+		p.Planes[i] = make([]uint16, w*h)
+	}
+	return p
+}
+
+func (p *Planar) ColorModel() color.Model {
+	return color.NRGBA64Model
+}
+
+func (p *Planar) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Planar) At(x, y int) color.Color {
+	return p.NRGBA64At(x, y)
+}
+
+// NRGBA64At returns the color of the pixel at the given coordinates.
+func (p *Planar) NRGBA64At(x, y int) color.NRGBA64 {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return color.NRGBA64{}
+	}
+
+	i := p.PlaneOffset(x, y)
+	return color.NRGBA64{
+		R: p.Planes[0][i],
+		G: p.Planes[1][i],
+		B: p.Planes[2][i],
+		A: p.Planes[3][i],
+	}
+}
+
+// PlaneOffset returns the index of the pixel at the given coordinates in each
+// of the planes.
+func (p *Planar) PlaneOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
+
+func (p *Planar) Set(x, y int, c color.Color) {
+	p.SetNRGBA64(x, y, color.NRGBA64Model.Convert(c).(color.NRGBA64))
+}
+
+// SetNRGBA64 sets the color of the pixel at the given coordinates.
+func (p *Planar) SetNRGBA64(x, y int, c color.NRGBA64) {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return
+	}
+
+	i := p.PlaneOffset(x, y)
+	p.Planes[0][i] = c.R
+	p.Planes[1][i] = c.G
+	p.Planes[2][i] = c.B
+	p.Planes[3][i] = c.A
+}
+
+// SubImage returns an image representing the portion of the image visible
+// through r. The returned value shares pixels with the original image.
+func (p *Planar) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Planar{}
+	}
+
+	sub := &Planar{
+		Stride: p.Stride,
+		Rect:   r,
+	}
+	i := p.PlaneOffset(r.Min.X, r.Min.Y)
+	for j := range p.Planes {
+		sub.Planes[j] = p.Planes[j][i:]
+	}
+	return sub
+}
+
+// Channel returns a WritableChannel that reads and writes plane i of the
+// image in place, where 0 is red, 1 green, 2 blue and 3 alpha.
+func (p *Planar) Channel(i int) WritableChannel {
+	return &planeView{
+		rect:   p.Rect,
+		plane:  p.Planes[i],
+		stride: p.Stride,
+	}
+}
+
+// Channels returns red, green, blue and alpha WritableChannels that read and
+// write the planes of the image in place.
+func (p *Planar) Channels() (r, g, b, a WritableChannel) {
+	return p.Channel(0), p.Channel(1), p.Channel(2), p.Channel(3)
+}
+
+// planeView is a WritableChannel that reads and writes a plane of a Planar.
+type planeView struct {
+	rect   image.Rectangle
+	plane  []uint16
+	stride int
+}
+
+func (v *planeView) Bounds() image.Rectangle {
+	return v.rect
+}
+
+func (v *planeView) ColorModel() color.Model {
+	return color.Gray16Model
+}
+
+func (v *planeView) At(x, y int) color.Color {
+	return v.Gray16At(x, y)
+}
+
+func (v *planeView) Gray16At(x, y int) color.Gray16 {
+	if !(image.Point{X: x, Y: y}.In(v.rect)) {
+		return color.Gray16{}
+	}
+	return color.Gray16{Y: v.plane[(y-v.rect.Min.Y)*v.stride+(x-v.rect.Min.X)]}
+}
+
+func (v *planeView) Set(x, y int, c color.Color) {
+	v.SetGray16(x, y, color.Gray16Model.Convert(c).(color.Gray16))
+}
+
+func (v *planeView) SetGray16(x, y int, c color.Gray16) {
+	if !(image.Point{X: x, Y: y}.In(v.rect)) {
+		return
+	}
+	v.plane[(y-v.rect.Min.Y)*v.stride+(x-v.rect.Min.X)] = c.Y
+}
+
+// ConvertToPlanar returns a *Planar instance by asserting the given
+// ImageReader has that type or, if it does not, using Copy to concurrently
+// set the color of each pixel of a new instance.
+func ConvertToPlanar(src ImageReader) *Planar {
+	if dst, ok := src.(*Planar); ok {
+		return dst
+	}
+	dst := NewPlanar(src.Bounds())
+	Copy(dst, src)
+	return dst
+}
+
+// unpremultiply returns the components of an alpha-premultiplied color, as
+// returned by color.Color's RGBA method, without alpha premultiplication, as
+// color.NRGBA64Model does.
+func unpremultiply(r, g, b, a uint32) (uint16, uint16, uint16, uint16) {
+	switch a {
+	case math.MaxUint16:
+	case 0:
+		return 0, 0, 0, 0
+	default:
+		r = (r * math.MaxUint16) / a
+		g = (g * math.MaxUint16) / a
+		b = (b * math.MaxUint16) / a
+	}
+	return uint16(r), uint16(g), uint16(b), uint16(a)
+}
+
+// toPlanarCopier returns a pixCopier from src to a *Planar if src has one of
+// the standard interleaved types or is itself a *Planar, and nil otherwise.
+func toPlanarCopier(d *Planar, src ImageReader) pixCopier {
+	// The source's Pix slice, PixOffset and bytes per pixel, and a function
+	// returning the color of a pixel given the start of its data.
+	var (
+		pix    []uint8
+		offset func(x, y int) int
+		bpp    int
+		pixel  func([]uint8) (r, g, b, a uint16)
+	)
+
+	switch s := src.(type) {
+	case *Planar:
+		return func(r image.Rectangle, sp image.Point) {
+			n := r.Dx()
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				di := d.PlaneOffset(r.Min.X, y)
+				si := s.PlaneOffset(sp.X, sp.Y+y-r.Min.Y)
+				for i := range d.Planes {
+					copy(d.Planes[i][di:di+n], s.Planes[i][si:si+n])
+				}
+			}
+		}
+	case *image.NRGBA64:
+		pix, offset, bpp = s.Pix, s.PixOffset, 8
+		pixel = func(b []uint8) (uint16, uint16, uint16, uint16) {
+			return get16(b[0:]), get16(b[2:]), get16(b[4:]), get16(b[6:])
+		}
+	case *image.RGBA64:
+		pix, offset, bpp = s.Pix, s.PixOffset, 8
+		pixel = func(b []uint8) (uint16, uint16, uint16, uint16) {
+			return unpremultiply(uint32(get16(b[0:])), uint32(get16(b[2:])), uint32(get16(b[4:])), uint32(get16(b[6:])))
+		}
+	case *image.NRGBA:
+		pix, offset, bpp = s.Pix, s.PixOffset, 4
+		pixel = func(b []uint8) (uint16, uint16, uint16, uint16) {
+			return unpremultiply(color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}.RGBA())
+		}
+	case *image.RGBA:
+		pix, offset, bpp = s.Pix, s.PixOffset, 4
+		pixel = func(b []uint8) (uint16, uint16, uint16, uint16) {
+			return unpremultiply(color.RGBA{R: b[0], G: b[1], B: b[2], A: b[3]}.RGBA())
+		}
+	case *image.Gray16:
+		pix, offset, bpp = s.Pix, s.PixOffset, 2
+		pixel = func(b []uint8) (uint16, uint16, uint16, uint16) {
+			y := get16(b)
+			return y, y, y, math.MaxUint16
+		}
+	case *image.Gray:
+		pix, offset, bpp = s.Pix, s.PixOffset, 1
+		pixel = func(b []uint8) (uint16, uint16, uint16, uint16) {
+			y := uint16(b[0]) * 0x101
+			return y, y, y, math.MaxUint16
+		}
+	default:
+		return nil
+	}
+
+	return func(r image.Rectangle, sp image.Point) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			di := d.PlaneOffset(r.Min.X, y)
+			si := offset(sp.X, sp.Y+y-r.Min.Y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				d.Planes[0][di], d.Planes[1][di], d.Planes[2][di], d.Planes[3][di] = pixel(pix[si : si+bpp])
+				di++
+				si += bpp
+			}
+		}
+	}
+}
+
+// fromPlanarCopier returns a pixCopier from a *Planar to an image with four
+// components per pixel, such as an *image.NRGBA64, using set to store the
+// color of each pixel given the start of its data.
+func fromPlanarCopier(dPix []uint8, dOffset func(x, y int) int, bpp int, s *Planar, set func(b []uint8, c color.NRGBA64)) pixCopier {
+	return func(r image.Rectangle, sp image.Point) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			di := dOffset(r.Min.X, y)
+			si := s.PlaneOffset(sp.X, sp.Y+y-r.Min.Y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				set(dPix[di:di+bpp], color.NRGBA64{
+					R: s.Planes[0][si],
+					G: s.Planes[1][si],
+					B: s.Planes[2][si],
+					A: s.Planes[3][si],
+				})
+				di += bpp
+				si++
+			}
+		}
+	}
+}
+
+// setNRGBA64Pix stores a color in the data of a pixel of an *image.NRGBA64.
+func setNRGBA64Pix(b []uint8, c color.NRGBA64) {
+	put16(b[0:], c.R)
+	put16(b[2:], c.G)
+	put16(b[4:], c.B)
+	put16(b[6:], c.A)
+}
+
+// setRGBA64Pix stores a color in the data of a pixel of an *image.RGBA64.
+func setRGBA64Pix(b []uint8, c color.NRGBA64) {
+	r, g, bl, a := c.RGBA()
+	put16(b[0:], uint16(r))
+	put16(b[2:], uint16(g))
+	put16(b[4:], uint16(bl))
+	put16(b[6:], uint16(a))
+}
+
+// setNRGBAPix stores a color in the data of a pixel of an *image.NRGBA, as
+// color.NRGBAModel would convert it.
+func setNRGBAPix(b []uint8, c color.NRGBA64) {
+	r, g, bl, a := unpremultiply(c.RGBA())
+	b[0] = uint8(r >> 8)
+	b[1] = uint8(g >> 8)
+	b[2] = uint8(bl >> 8)
+	b[3] = uint8(a >> 8)
+}
+
+// setRGBAPix stores a color in the data of a pixel of an *image.RGBA.
+func setRGBAPix(b []uint8, c color.NRGBA64) {
+	r, g, bl, a := c.RGBA()
+	b[0] = uint8(r >> 8)
+	b[1] = uint8(g >> 8)
+	b[2] = uint8(bl >> 8)
+	b[3] = uint8(a >> 8)
+}
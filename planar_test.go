@@ -0,0 +1,104 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestPlanarCopyFast(t *testing.T) {
+	for name, src := range copyTestSources() {
+		expected := NewPlanar(copyTestRect)
+		Copy(expected, opaqueImage{src})
+
+		dst := ConvertToPlanar(src)
+		if !reflect.DeepEqual(dst, expected) {
+			t.Errorf("copying %s to Planar differed from the generic copy", name)
+		}
+	}
+
+	src := ConvertToPlanar(randomNRGBA64(copyTestRect))
+	for name, newDst := range copyTestDestinations() {
+		expected := newDst()
+		Copy(expected, opaqueImage{src})
+
+		dst := newDst()
+		Copy(dst, src)
+
+		if !bytes.Equal(pix(dst), pix(expected)) {
+			t.Errorf("copying Planar to %s differed from the generic copy", name)
+		}
+	}
+
+	if dst := ConvertToPlanar(src); dst != src {
+		t.Error("converting a Planar to Planar copied it")
+	}
+}
+
+func TestPlanarSubImage(t *testing.T) {
+	p := ConvertToPlanar(randomNRGBA64(copyTestRect))
+	rect := image.Rect(4, 9, 40, 33)
+	sub := p.SubImage(rect).(*Planar)
+	if sub.Bounds() != rect {
+		t.Errorf("sub-image has bounds %v, expected %v", sub.Bounds(), rect)
+	}
+
+	// Copying between sub-images of the same size uses the fast path.
+	dst := NewPlanar(rect)
+	Copy(dst, sub)
+
+	c := color.NRGBA64{R: 1, G: 2, B: 3, A: 4}
+	sub.SetNRGBA64(rect.Min.X, rect.Min.Y, c)
+	if p.NRGBA64At(rect.Min.X, rect.Min.Y) != c {
+		t.Error("setting a pixel of a sub-image didn't set it in the original image")
+	}
+
+	AllPointsRP(
+		func(pt image.Point) {
+			if pt != rect.Min && dst.NRGBA64At(pt.X, pt.Y) != p.NRGBA64At(pt.X, pt.Y) {
+				t.Errorf("sub-image copied %v at %v, expected %v", dst.NRGBA64At(pt.X, pt.Y), pt, p.NRGBA64At(pt.X, pt.Y))
+			}
+		},
+	)(rect)
+}
+
+func TestPlanarChannels(t *testing.T) {
+	p := ConvertToPlanar(randomNRGBA64(copyTestRect))
+	r, g, b, a := p.Channels()
+	for i, c := range []WritableChannel{r, g, b, a} {
+		if c.Bounds() != p.Rect {
+			t.Errorf("channel %d has bounds %v, expected %v", i, c.Bounds(), p.Rect)
+		}
+
+		AllPointsRP(
+			func(pt image.Point) {
+				j := p.PlaneOffset(pt.X, pt.Y)
+				if c.Gray16At(pt.X, pt.Y).Y != p.Planes[i][j] {
+					t.Errorf("channel %d reads %v at %v, expected %v", i, c.Gray16At(pt.X, pt.Y).Y, pt, p.Planes[i][j])
+				}
+
+				c.SetGray16(pt.X, pt.Y, color.Gray16{Y: uint16(i + pt.X + pt.Y)})
+				if p.Planes[i][j] != uint16(i+pt.X+pt.Y) {
+					t.Errorf("channel %d wrote %v at %v, expected %v", i, p.Planes[i][j], pt, i+pt.X+pt.Y)
+				}
+			},
+		)(p.Rect)
+	}
+}
+
+func BenchmarkEdgesPlanar(b *testing.B) {
+	p := ConvertToPlanar(randomNRGBA64(image.Rect(0, 0, 256, 256)))
+	for i := 0; i < b.N; i++ {
+		EdgesGray16(5, p.Channel(0))
+	}
+}
+
+func BenchmarkEdgesNRGBA64Channel(b *testing.B) {
+	img := randomNRGBA64(image.Rect(0, 0, 256, 256)).(*image.NRGBA64)
+	for i := 0; i < b.N; i++ {
+		r, _, _, _ := NRGBA64ToChannels(img)
+		EdgesGray16(5, r)
+	}
+}
@@ -0,0 +1,75 @@
+package imageutil
+
+import (
+	"image"
+	"sync"
+)
+
+// Progress is a progress observer, any function that accepts the fraction of
+// an operation, between 0 and 1, that has been completed. Calls to a Progress
+// made on behalf of a single operation are serialized and non-decreasing,
+// even when the operation is running concurrently.
+type Progress func(float64)
+
+// ProgressChan returns a Progress that sends each fraction on the given
+// channel without blocking, dropping any that the channel isn't ready to
+// receive. A buffered channel will drop fewer updates.
+func ProgressChan(c chan<- float64) Progress {
+	return func(fraction float64) {
+		select {
+		case c <- fraction:
+		default:
+		}
+	}
+}
+
+// stage returns a Progress that reports to p as the i-th of n equal stages of
+// a larger operation. The stage of a nil Progress is nil.
+func (p Progress) stage(i, n int) Progress {
+	if p == nil {
+		return nil
+	}
+
+	return func(fraction float64) {
+		p((float64(i) + fraction) / float64(n))
+	}
+}
+
+// progressCounter accumulates completed area towards a total, reporting each
+// update to a Progress.
+type progressCounter struct {
+	mu    sync.Mutex
+	done  int
+	total int
+	p     Progress
+}
+
+// add adds the given completed area and reports the new fraction.
+func (c *progressCounter) add(area int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done += area
+	c.p(float64(c.done) / float64(c.total))
+}
+
+// ProgressRP returns a RP that calls the given RP on an input rectangle and
+// then reports the fraction of the total area, across all of its calls, that
+// has been processed. The returned RP may be used concurrently, for instance
+// within QuickRP.
+func ProgressRP(total image.Rectangle, p Progress, rp RP) RP {
+	if p == nil || total.Empty() {
+		return rp
+	}
+
+	c := &progressCounter{
+		total: total.Dx() * total.Dy(),
+		p:     p,
+	}
+	return func(rect image.Rectangle) {
+		rp(rect)
+
+		r := rect.Intersect(total)
+		c.add(r.Dx() * r.Dy())
+	}
+}
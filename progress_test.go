@@ -0,0 +1,82 @@
+package imageutil
+
+import (
+	"image"
+	"testing"
+)
+
+// testProgress returns a Progress that fails the test if it's ever called with
+// a decreasing or out of range fraction, along with a pointer to the last
+// fraction reported.
+func testProgress(t *testing.T) (Progress, *float64) {
+	last := new(float64)
+	return func(fraction float64) {
+		if fraction < *last || fraction > 1 {
+			t.Errorf("progress went from %v to %v", *last, fraction)
+		}
+		*last = fraction
+	}, last
+}
+
+func TestProgressRP(t *testing.T) {
+	rect := image.Rect(0, 0, 300, 200)
+	p, last := testProgress(t)
+
+	QuickRP(ProgressRP(rect, p, func(image.Rectangle) {}))(rect)
+
+	if *last != 1 {
+		t.Error("expected a final fraction of 1, found", *last)
+	}
+}
+
+func TestSchedulerWithProgress(t *testing.T) {
+	rect := image.Rect(0, 0, 300, 200)
+	p, last := testProgress(t)
+
+	NewScheduler(4, image.Pt(7, 9)).WithProgress(p).RP(func(image.Rectangle) {})(rect)
+
+	if *last != 1 {
+		t.Error("expected a final fraction of 1, found", *last)
+	}
+}
+
+func TestCopyProgress(t *testing.T) {
+	src := randomNRGBA64(image.Rect(0, 0, 300, 200))
+	dst := image.NewNRGBA64(src.Bounds())
+	p, last := testProgress(t)
+
+	CopyProgress(dst, src, p)
+
+	if *last != 1 {
+		t.Error("expected a final fraction of 1, found", *last)
+	}
+}
+
+func TestEdgesNRGBA64Progress(t *testing.T) {
+	src := randomNRGBA64(image.Rect(0, 0, 300, 200)).(*image.NRGBA64)
+	p, last := testProgress(t)
+
+	EdgesNRGBA64Progress(3, src, p)
+
+	if *last != 1 {
+		t.Error("expected a final fraction of 1, found", *last)
+	}
+}
+
+func TestProgressChan(t *testing.T) {
+	c := make(chan float64, 1)
+	p := ProgressChan(c)
+
+	p(0.5)
+	p(0.75)
+
+	if fraction := <-c; fraction != 0.5 {
+		t.Error("expected 0.5, found", fraction)
+	}
+
+	select {
+	case fraction := <-c:
+		t.Error("expected the second update to be dropped, found", fraction)
+	default:
+	}
+}
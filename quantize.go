@@ -0,0 +1,513 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+)
+
+// histogram concurrently counts the occurrences of each color of an
+// ImageReader, reduced to color.RGBA.
+func histogram(src ImageReader) map[color.RGBA]int {
+	var (
+		mu     sync.Mutex
+		counts = make(map[color.RGBA]int)
+	)
+
+	QuickRP(
+		func(rect image.Rectangle) {
+			local := make(map[color.RGBA]int)
+			AllPointsRP(
+				func(pt image.Point) {
+					local[color.RGBAModel.Convert(src.At(pt.X, pt.Y)).(color.RGBA)]++
+				},
+			)(rect)
+
+			mu.Lock()
+			for c, n := range local {
+				counts[c] += n
+			}
+			mu.Unlock()
+		},
+	)(src.Bounds())
+
+	return counts
+}
+
+// colorCount is a color and the number of times it occurs.
+type colorCount struct {
+	c [4]uint8
+	n int
+}
+
+// colorBox is a set of colors for median cut.
+type colorBox []colorCount
+
+// widest returns the channel over which the colors in the box span the
+// widest range, and that range.
+func (b colorBox) widest() (channel, width int) {
+	for i := 0; i < 4; i++ {
+		lo, hi := uint8(math.MaxUint8), uint8(0)
+		for _, cc := range b {
+			if cc.c[i] < lo {
+				lo = cc.c[i]
+			}
+			if cc.c[i] > hi {
+				hi = cc.c[i]
+			}
+		}
+		if w := int(hi) - int(lo); w > width {
+			channel, width = i, w
+		}
+	}
+	return
+}
+
+// average returns the average color in the box, weighted by count.
+func (b colorBox) average() color.RGBA {
+	var sums [4]int
+	var n int
+	for _, cc := range b {
+		for i := range sums {
+			sums[i] += int(cc.c[i]) * cc.n
+		}
+		n += cc.n
+	}
+
+	return color.RGBA{
+		R: uint8((sums[0] + n/2) / n),
+		G: uint8((sums[1] + n/2) / n),
+		B: uint8((sums[2] + n/2) / n),
+		A: uint8((sums[3] + n/2) / n),
+	}
+}
+
+// maxPaletteColors is the number of colors an *image.Paletted can index.
+const maxPaletteColors = 256
+
+// MedianCutPalette returns a palette of at most n colors, and no more than
+// 256, for an ImageReader using the median cut algorithm: the set of colors
+// in the image is repeatedly split at its median along its widest channel,
+// and each of the resulting sets contributes its average color.
+func MedianCutPalette(src ImageReader, n int) color.Palette {
+	if n <= 0 {
+		return nil
+	}
+	if n > maxPaletteColors {
+		n = maxPaletteColors
+	}
+
+	var all colorBox
+	for c, count := range histogram(src) {
+		all = append(all, colorCount{c: [4]uint8{c.R, c.G, c.B, c.A}, n: count})
+	}
+	if len(all) == 0 {
+		return nil
+	}
+
+	// Track the widest channel of each box alongside it.
+	type split struct {
+		box            colorBox
+		channel, width int
+	}
+	newSplit := func(b colorBox) split {
+		c, w := b.widest()
+		return split{b, c, w}
+	}
+
+	splits := []split{newSplit(all)}
+	for len(splits) < n {
+
+		// Find the box spanning the widest range of any channel.
+		widest := 0
+		for i, s := range splits {
+			if s.width > splits[widest].width {
+				widest = i
+			}
+		}
+		if splits[widest].width == 0 {
+			break
+		}
+
+		// Split it at the median along that channel, weighted by count.
+		b, channel := splits[widest].box, splits[widest].channel
+		sort.Slice(b, func(i, j int) bool {
+			return b[i].c[channel] < b[j].c[channel]
+		})
+
+		var total int
+		for _, cc := range b {
+			total += cc.n
+		}
+
+		m, seen := 1, b[0].n
+		for ; m < len(b)-1 && seen+b[m].n <= total/2; m++ {
+			seen += b[m].n
+		}
+
+		splits[widest] = newSplit(b[:m])
+		splits = append(splits, newSplit(b[m:]))
+	}
+
+	boxes := make([]colorBox, len(splits))
+	for i, s := range splits {
+		boxes[i] = s.box
+	}
+
+	p := make(color.Palette, len(boxes))
+	for i, b := range boxes {
+		p[i] = b.average()
+	}
+	return p
+}
+
+// octreeDepth is the number of levels in an octree below its root.
+const octreeDepth = 8
+
+// octreeNode is a node in an octree. Since it also divides the alpha
+// channel, each node has sixteen children rather than eight.
+type octreeNode struct {
+	children [16]*octreeNode
+	leaf     bool
+	n        int
+	sums     [4]int
+}
+
+// octree is a tree of colors that can be reduced to a limited number of
+// leaves by merging similar colors.
+type octree struct {
+	root   octreeNode
+	levels [octreeDepth][]*octreeNode
+	sorted [octreeDepth]bool
+	leaves int
+}
+
+// insert adds n occurrences of a color to the tree.
+func (t *octree) insert(c color.RGBA, n int) {
+	node := &t.root
+	for level := 0; ; level++ {
+		node.n += n
+		node.sums[0] += int(c.R) * n
+		node.sums[1] += int(c.G) * n
+		node.sums[2] += int(c.B) * n
+		node.sums[3] += int(c.A) * n
+		if node.leaf {
+			return
+		}
+
+		if level == octreeDepth {
+			node.leaf = true
+			t.leaves++
+			return
+		}
+
+		shift := uint(7 - level)
+		i := (c.R>>shift&1)<<3 | (c.G>>shift&1)<<2 | (c.B>>shift&1)<<1 | c.A>>shift&1
+		if node.children[i] == nil {
+			node.children[i] = new(octreeNode)
+			if level+1 < octreeDepth {
+				t.levels[level+1] = append(t.levels[level+1], node.children[i])
+			}
+		}
+		node = node.children[i]
+	}
+}
+
+// reduce merges the children of the least populous node at the deepest level
+// with any nodes that aren't yet leaves into it.
+func (t *octree) reduce() {
+	level := octreeDepth - 1
+	for level > 0 && len(t.levels[level]) == 0 {
+		level--
+	}
+
+	node := &t.root
+	if level > 0 {
+
+		// The populations of the nodes at a level don't change as deeper
+		// nodes are merged, so they need only be sorted once.
+		nodes := t.levels[level]
+		if !t.sorted[level] {
+			sort.Slice(nodes, func(i, j int) bool {
+				return nodes[i].n < nodes[j].n
+			})
+			t.sorted[level] = true
+		}
+		node, t.levels[level] = nodes[0], nodes[1:]
+	}
+
+	for i, child := range node.children {
+		if child != nil {
+			if child.leaf {
+				t.leaves--
+			}
+			node.children[i] = nil
+		}
+	}
+	node.leaf = true
+	t.leaves++
+}
+
+// colors appends the average color of each leaf below the node to p.
+func (node *octreeNode) colors(p color.Palette) color.Palette {
+	if node.leaf {
+		return append(p, color.RGBA{
+			R: uint8((node.sums[0] + node.n/2) / node.n),
+			G: uint8((node.sums[1] + node.n/2) / node.n),
+			B: uint8((node.sums[2] + node.n/2) / node.n),
+			A: uint8((node.sums[3] + node.n/2) / node.n),
+		})
+	}
+
+	for _, child := range node.children {
+		if child != nil {
+			p = child.colors(p)
+		}
+	}
+	return p
+}
+
+// OctreePalette returns a palette of at most n colors, and no more than 256,
+// for an ImageReader using octree quantization: the colors in the image are
+// placed in a tree dividing each channel bit by bit, and the least populous
+// branches are merged until at most n leaves remain, each contributing its
+// average color.
+func OctreePalette(src ImageReader, n int) color.Palette {
+	if n <= 0 {
+		return nil
+	}
+	if n > maxPaletteColors {
+		n = maxPaletteColors
+	}
+
+	var t octree
+	for c, count := range histogram(src) {
+		t.insert(c, count)
+	}
+	if t.root.n == 0 {
+		return nil
+	}
+
+	// Merging a node may only replace its children with itself, so reduce
+	// until there are few enough leaves.
+	for t.leaves > n {
+		t.reduce()
+	}
+
+	return t.root.colors(nil)
+}
+
+// Ditherer sets the pixels of a destination *image.Paletted to colors from
+// its palette approximating the colors of a source ImageReader at the same
+// coordinates.
+type Ditherer interface {
+	Dither(dst *image.Paletted, src ImageReader)
+}
+
+// errorDiffusion is a Ditherer that distributes the difference between each
+// source color and its closest palette color to the neighbouring pixels that
+// have not yet been processed.
+type errorDiffusion struct {
+	weights []diffusionWeight
+	divisor int32
+}
+
+// diffusionWeight is the share of the error of a pixel given to the pixel at
+// the offset (dx, dy) from it.
+type diffusionWeight struct {
+	dx, dy int
+	w      int32
+}
+
+var (
+	// FloydSteinberg is the Floyd–Steinberg error diffusion Ditherer.
+	FloydSteinberg Ditherer = errorDiffusion{
+		weights: []diffusionWeight{
+			{1, 0, 7},
+			{-1, 1, 3}, {0, 1, 5}, {1, 1, 1},
+		},
+		divisor: 16,
+	}
+
+	// Atkinson is the Atkinson error diffusion Ditherer, which diffuses only
+	// three quarters of the error for higher contrast.
+	Atkinson Ditherer = errorDiffusion{
+		weights: []diffusionWeight{
+			{1, 0, 1}, {2, 0, 1},
+			{-1, 1, 1}, {0, 1, 1}, {1, 1, 1},
+			{0, 2, 1},
+		},
+		divisor: 8,
+	}
+)
+
+// Dither sets the pixels of dst row by row. Error diffusion is inherently
+// sequential, so unlike most operations in this package it does not run
+// concurrently.
+func (d errorDiffusion) Dither(dst *image.Paletted, src ImageReader) {
+	bounds := dst.Bounds().Intersect(src.Bounds())
+	if bounds.Empty() {
+		return
+	}
+
+	// Keep the errors for as many rows as the weights reach, with a margin on
+	// either side for the columns they reach.
+	height, margin := 0, 0
+	for _, w := range d.weights {
+		if w.dy > height {
+			height = w.dy
+		}
+		if w.dx > margin {
+			margin = w.dx
+		} else if -w.dx > margin {
+			margin = -w.dx
+		}
+	}
+	width := bounds.Dx() + 2*margin
+	errs := make([][][4]int32, height+1)
+	for i := range errs {
+		errs[i] = make([][4]int32, width)
+	}
+
+	palette := make([][4]int32, len(dst.Palette))
+	for i, c := range dst.Palette {
+		r, g, b, a := c.RGBA()
+		palette[i] = [4]int32{int32(r), int32(g), int32(b), int32(a)}
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := errs[0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := src.At(x, y).RGBA()
+			e := row[x-bounds.Min.X+margin]
+
+			c := [4]int32{int32(r) + e[0], int32(g) + e[1], int32(b) + e[2], int32(a) + e[3]}
+			for i := range c {
+				if c[i] < 0 {
+					c[i] = 0
+				} else if c[i] > math.MaxUint16 {
+					c[i] = math.MaxUint16
+				}
+			}
+
+			i := dst.Palette.Index(color.RGBA64{
+				R: uint16(c[0]),
+				G: uint16(c[1]),
+				B: uint16(c[2]),
+				A: uint16(c[3]),
+			})
+			dst.SetColorIndex(x, y, uint8(i))
+
+			// Diffuse the error.
+			for _, w := range d.weights {
+				target := &errs[w.dy][x-bounds.Min.X+margin+w.dx]
+				for j := range c {
+					target[j] += (c[j] - palette[i][j]) * w.w / d.divisor
+				}
+			}
+		}
+
+		// Rotate the rows of errors, clearing the row for the furthest.
+		copy(errs, errs[1:])
+		for i := range row {
+			row[i] = [4]int32{}
+		}
+		errs[height] = row
+	}
+}
+
+// ordered is a Ditherer that offsets each source color by a threshold taken
+// from a repeating matrix before choosing the closest palette color.
+type ordered struct {
+	n         int
+	threshold []float64
+}
+
+// Bayer returns an ordered Ditherer using an n by n Bayer threshold matrix,
+// where n is rounded up to a power of two.
+func Bayer(n int) Ditherer {
+	size := 1
+	for size < n {
+		size *= 2
+	}
+
+	// Build the matrix by repeatedly tiling it in four quadrants.
+	m := []int{0}
+	for s := 1; s < size; s *= 2 {
+		next := make([]int, 4*s*s)
+		for y := 0; y < s; y++ {
+			for x := 0; x < s; x++ {
+				v := 4 * m[y*s+x]
+				next[y*2*s+x] = v
+				next[y*2*s+x+s] = v + 2
+				next[(y+s)*2*s+x] = v + 3
+				next[(y+s)*2*s+x+s] = v + 1
+			}
+		}
+		m = next
+	}
+
+	threshold := make([]float64, len(m))
+	for i, v := range m {
+		threshold[i] = (float64(v)+0.5)/float64(len(m)) - 0.5
+	}
+
+	return ordered{
+		n:         size,
+		threshold: threshold,
+	}
+}
+
+// Dither concurrently sets the pixels of dst.
+func (d ordered) Dither(dst *image.Paletted, src ImageReader) {
+	if len(dst.Palette) == 0 {
+		return
+	}
+
+	// Spread the thresholds over roughly the distance between neighbouring
+	// levels of a palette spread evenly over the color cube.
+	spread := math.MaxUint16 / math.Max(1, math.Cbrt(float64(len(dst.Palette)))-1)
+
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				offset := spread * d.threshold[(pt.Y&(d.n-1))*d.n+(pt.X&(d.n-1))]
+				r, g, b, a := src.At(pt.X, pt.Y).RGBA()
+
+				c := color.RGBA64{
+					R: clampUint16(float64(r) + offset),
+					G: clampUint16(float64(g) + offset),
+					B: clampUint16(float64(b) + offset),
+					A: uint16(a),
+				}
+				dst.SetColorIndex(pt.X, pt.Y, uint8(dst.Palette.Index(c)))
+			},
+		),
+	)(dst.Bounds().Intersect(src.Bounds()))
+}
+
+// clampUint16 rounds a value to the nearest integer between 0 and
+// math.MaxUint16.
+func clampUint16(v float64) uint16 {
+	return uint16(math.Max(0, math.Min(math.MaxUint16, v+0.5)))
+}
+
+// Quantize returns an *image.Paletted with the given palette and the bounds
+// of the given ImageReader, approximating its colors using the given
+// Ditherer, or with the closest palette color if the Ditherer is nil. Only
+// the first 256 colors of a longer palette are used, since an
+// *image.Paletted can't index any more.
+func Quantize(src ImageReader, p color.Palette, d Ditherer) *image.Paletted {
+	if len(p) > maxPaletteColors {
+		p = p[:maxPaletteColors]
+	}
+	if d == nil {
+		return ConvertToPaletted(src, p)
+	}
+
+	dst := image.NewPaletted(src.Bounds(), p)
+	d.Dither(dst, src)
+	return dst
+}
@@ -0,0 +1,143 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestPalettes(t *testing.T) {
+	src := randomNRGBA64(image.Rect(0, 0, 64, 64))
+
+	// Few colors should be reproduced exactly.
+	colors := []color.RGBA{
+		{R: 0xff, A: 0xff},
+		{G: 0x80, A: 0xff},
+		{B: 0x40, A: 0x80},
+		{R: 0x10, G: 0x20, B: 0x30, A: 0xff},
+	}
+	few := image.NewRGBA(image.Rect(-3, 5, 13, 21))
+	AllPointsRP(
+		func(pt image.Point) {
+			few.SetRGBA(pt.X, pt.Y, colors[(pt.X+pt.Y)&3])
+		},
+	)(few.Bounds())
+
+	for name, palette := range map[string]func(ImageReader, int) color.Palette{
+		"median cut": MedianCutPalette,
+		"octree":     OctreePalette,
+	} {
+		for _, n := range []int{1, 2, 16, 256, 300} {
+			if p := palette(src, n); len(p) == 0 || len(p) > n || len(p) > 256 {
+				t.Errorf("%s palette for %d colors has %d", name, n, len(p))
+			}
+		}
+
+		p := palette(few, 16)
+		if len(p) != len(colors) {
+			t.Errorf("%s palette has %d colors, expected %d", name, len(p), len(colors))
+		}
+		for _, c := range colors {
+			if p[p.Index(c)] != c {
+				t.Errorf("%s palette doesn't contain %v", name, c)
+			}
+		}
+
+		if p := palette(src, 0); p != nil {
+			t.Errorf("%s palette for no colors is %v", name, p)
+		}
+	}
+}
+
+func TestQuantize(t *testing.T) {
+
+	// A gradient from black to white.
+	src := image.NewGray16(image.Rect(-2, 3, 254, 67))
+	AllPointsRP(
+		func(pt image.Point) {
+			src.SetGray16(pt.X, pt.Y, color.Gray16{Y: uint16((pt.X - src.Rect.Min.X) * 0x101)})
+		},
+	)(src.Bounds())
+	p := color.Palette{color.Black, color.White}
+
+	for name, d := range map[string]Ditherer{
+		"nearest":         nil,
+		"Floyd–Steinberg": FloydSteinberg,
+		"Atkinson":        Atkinson,
+		"Bayer":           Bayer(4),
+	} {
+		dst := Quantize(src, p, d)
+		if dst.Bounds() != src.Bounds() {
+			t.Errorf("%s quantized bounds %v, expected %v", name, dst.Bounds(), src.Bounds())
+		}
+		if len(dst.Palette) != len(p) {
+			t.Errorf("%s quantized palette has %d colors", name, len(dst.Palette))
+		}
+		if d == nil {
+			continue
+		}
+
+		// Dithering should preserve the average brightness of each group of
+		// four columns.
+		var worst float64
+		for x := src.Rect.Min.X; x < src.Rect.Max.X; x += 4 {
+			var sum, expected float64
+			for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+				for dx := 0; dx < 4; dx++ {
+					sum += float64(dst.ColorIndexAt(x+dx, y))
+					expected += float64(src.Gray16At(x+dx, y).Y) / math.MaxUint16
+				}
+			}
+			worst = math.Max(worst, math.Abs(sum-expected)/float64(4*src.Rect.Dy()))
+		}
+		if worst > 0.15 {
+			t.Errorf("%s dithering changed the brightness of columns by %v", name, worst)
+		}
+	}
+
+	// Only the first 256 colors of a longer palette can be indexed.
+	long := make(color.Palette, 300)
+	for i := range long {
+		if i < 256 {
+			long[i] = color.RGBA{R: uint8(i), A: 0xff}
+		} else {
+			long[i] = color.Gray{Y: uint8((i - 256) * 6)}
+		}
+	}
+	for name, d := range map[string]Ditherer{
+		"nearest":         nil,
+		"Floyd–Steinberg": FloydSteinberg,
+		"Bayer":           Bayer(4),
+	} {
+		dst := Quantize(src, long, d)
+		if len(dst.Palette) != 256 {
+			t.Errorf("%s quantized palette has %d colors, expected 256", name, len(dst.Palette))
+		}
+		if d != nil {
+			continue
+		}
+		AllPointsRP(
+			func(pt image.Point) {
+				if c, expected := dst.At(pt.X, pt.Y), dst.Palette.Convert(src.At(pt.X, pt.Y)); c != expected {
+					t.Errorf("%s quantized %v to %v at %v, expected %v", name, src.At(pt.X, pt.Y), c, pt, expected)
+				}
+			},
+		)(src.Rect)
+	}
+}
+
+func BenchmarkOctreePalette(b *testing.B) {
+	src := randomNRGBA64(image.Rect(0, 0, 256, 256))
+	for i := 0; i < b.N; i++ {
+		OctreePalette(src, 256)
+	}
+}
+
+func BenchmarkFloydSteinberg(b *testing.B) {
+	src := randomNRGBA64(image.Rect(0, 0, 256, 256))
+	p := MedianCutPalette(src, 256)
+	for i := 0; i < b.N; i++ {
+		Quantize(src, p, FloydSteinberg)
+	}
+}
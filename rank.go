@@ -0,0 +1,366 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Window is the shape of the neighbourhood of each pixel that a rank filter
+// considers.
+type Window int
+
+const (
+	// WindowSquare is a square of pixels within the radius both horizontally
+	// and vertically.
+	WindowSquare Window = iota
+
+	// WindowCircle is a disk of pixels within the radius of the centre.
+	WindowCircle
+)
+
+// halfWidths returns the number of pixels either side of the centre that a
+// window with the given radius covers in each of its rows, from the top.
+func (w Window) halfWidths(radius int) []int {
+	widths := make([]int, 2*radius+1)
+	for i := range widths {
+		switch w {
+		case WindowCircle:
+			dy := i - radius
+			widths[i] = int(math.Sqrt(float64(radius*radius - dy*dy)))
+		default:
+			widths[i] = radius
+		}
+	}
+	return widths
+}
+
+// rankHistogram is a histogram of the values in a window that can find the
+// value with a given rank.
+type rankHistogram interface {
+	add(v uint16)
+	remove(v uint16)
+
+	// rank returns the value with the given index among the values sorted in
+	// ascending order.
+	rank(k int) uint16
+
+	reset()
+}
+
+// histogram8 is a rankHistogram of 8-bit values, each stored as a uint16 with
+// the same high and low bytes.
+type histogram8 [256]int32
+
+func (h *histogram8) add(v uint16) {
+	h[v>>8]++
+}
+
+func (h *histogram8) remove(v uint16) {
+	h[v>>8]--
+}
+
+func (h *histogram8) rank(k int) uint16 {
+	var n int
+	for i, c := range h {
+		if n += int(c); n > k {
+			return uint16(i) * 0x101
+		}
+	}
+	return math.MaxUint16
+}
+
+func (h *histogram8) reset() {
+	*h = histogram8{}
+}
+
+// histogram16 is a rankHistogram of 16-bit values, with a coarse level
+// counting the values with each high byte so that finding a rank only scans
+// 512 bins.
+type histogram16 struct {
+	coarse [256]int32
+	fine   [1 << 16]int32
+}
+
+func (h *histogram16) add(v uint16) {
+	h.coarse[v>>8]++
+	h.fine[v]++
+}
+
+func (h *histogram16) remove(v uint16) {
+	h.coarse[v>>8]--
+	h.fine[v]--
+}
+
+func (h *histogram16) rank(k int) uint16 {
+	var n int
+	for i, c := range h.coarse {
+		if n+int(c) <= k {
+			n += int(c)
+			continue
+		}
+		for j, f := range h.fine[i<<8 : (i+1)<<8] {
+			if n += int(f); n > k {
+				return uint16(i<<8 + j)
+			}
+		}
+	}
+	return math.MaxUint16
+}
+
+func (h *histogram16) reset() {
+	for i, c := range h.coarse {
+		if c != 0 {
+			h.coarse[i] = 0
+			fine := h.fine[i<<8 : (i+1)<<8]
+			for j := range fine {
+				fine[j] = 0
+			}
+		}
+	}
+}
+
+// is8Bit returns whether a Channel only holds 8-bit values.
+func is8Bit(c Channel) bool {
+	v, ok := c.(*pixView)
+	return ok && !v.wide
+}
+
+// rankIndex returns the index of the given percentile among n sorted values.
+func rankIndex(percentile float64, n int) int {
+	percentile = math.Max(0, math.Min(100, percentile))
+	return int(math.Round(percentile / 100 * float64(n-1)))
+}
+
+// PercentileGray16 concurrently replaces each value of a Channel with the
+// given percentile, from 0 for the minimum to 100 for the maximum, of the
+// values in a window of the given shape and radius around it, returning an
+// *image.Gray16 with the same bounds. Near the edges, only the values within
+// the bounds are considered. Channels of 8-bit values, such as the views
+// returned by GrayChannel, use a 256-bin histogram, updated in constant time
+// per pixel for square windows, and other channels use a two-level histogram
+// of 16-bit values slid along each row.
+func PercentileGray16(radius int, shape Window, percentile float64, img Channel) *image.Gray16 {
+	return percentileGray16(DefaultScheduler, radius, shape, percentile, img)
+}
+
+// MedianGray16 is PercentileGray16 with the 50th percentile, which removes
+// salt-and-pepper noise while keeping edges sharp.
+func MedianGray16(radius int, shape Window, img Channel) *image.Gray16 {
+	return PercentileGray16(radius, shape, 50, img)
+}
+
+// MinGray16 is PercentileGray16 with the minimum.
+func MinGray16(radius int, shape Window, img Channel) *image.Gray16 {
+	return PercentileGray16(radius, shape, 0, img)
+}
+
+// MaxGray16 is PercentileGray16 with the maximum.
+func MaxGray16(radius int, shape Window, img Channel) *image.Gray16 {
+	return PercentileGray16(radius, shape, 100, img)
+}
+
+// percentileGray16 implements PercentileGray16 using the given Scheduler.
+func percentileGray16(s *Scheduler, radius int, shape Window, percentile float64, img Channel) *image.Gray16 {
+	bounds := img.Bounds()
+	dst := image.NewGray16(bounds)
+	if radius < 0 {
+		radius = 0
+	}
+
+	switch {
+	case is8Bit(img) && shape == WindowSquare:
+		s.RowsRP(func(rect image.Rectangle) {
+			percentileSquare8(dst, img, radius, percentile, rect)
+		})(bounds)
+	default:
+		widths := shape.halfWidths(radius)
+		s.RowsRP(func(rect image.Rectangle) {
+			var h rankHistogram
+			if is8Bit(img) {
+				h = new(histogram8)
+			} else {
+				h = new(histogram16)
+			}
+			percentileSliding(dst, img, widths, percentile, h, rect)
+		})(bounds)
+	}
+	return dst
+}
+
+// percentileSliding sets the pixels of dst within rect to the given
+// percentile of the values of img in the window with the given half-widths,
+// sliding a histogram along each row by adding and removing the values at
+// either end of each of the window's rows.
+func percentileSliding(dst *image.Gray16, img Channel, widths []int, percentile float64, h rankHistogram, rect image.Rectangle) {
+	bounds := img.Bounds()
+	radius := len(widths) / 2
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		h.reset()
+		var n int
+		for i, w := range widths {
+			yy := y + i - radius
+			if yy < bounds.Min.Y || yy >= bounds.Max.Y {
+				continue
+			}
+			for xx := rect.Min.X - w; xx <= rect.Min.X+w; xx++ {
+				if xx >= bounds.Min.X && xx < bounds.Max.X {
+					h.add(img.Gray16At(xx, yy).Y)
+					n++
+				}
+			}
+		}
+
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			dst.SetGray16(x, y, color.Gray16{Y: h.rank(rankIndex(percentile, n))})
+
+			for i, w := range widths {
+				yy := y + i - radius
+				if yy < bounds.Min.Y || yy >= bounds.Max.Y {
+					continue
+				}
+				if xx := x - w; xx >= bounds.Min.X {
+					h.remove(img.Gray16At(xx, yy).Y)
+					n--
+				}
+				if xx := x + w + 1; xx < bounds.Max.X {
+					h.add(img.Gray16At(xx, yy).Y)
+					n++
+				}
+			}
+		}
+	}
+}
+
+// percentileSquare8 sets the pixels of dst within rect to the given
+// percentile of the 8-bit values of img in the square window with the given
+// radius, in constant time per pixel. It keeps a histogram of each column of
+// the window for every x, sliding each down a row at a time, and slides the
+// window's histogram along each row by adding and subtracting the column
+// histograms at either end.
+func percentileSquare8(dst *image.Gray16, img Channel, radius int, percentile float64, rect image.Rectangle) {
+	bounds := img.Bounds()
+	columns := make([]histogram8, bounds.Dx())
+	column := func(x int) *histogram8 {
+		return &columns[x-bounds.Min.X]
+	}
+
+	rows := 0
+	for yy := rect.Min.Y - radius; yy <= rect.Min.Y+radius; yy++ {
+		if yy < bounds.Min.Y || yy >= bounds.Max.Y {
+			continue
+		}
+		rows++
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			column(x).add(img.Gray16At(x, yy).Y)
+		}
+	}
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		if y > rect.Min.Y {
+			if yy := y - radius - 1; yy >= bounds.Min.Y {
+				rows--
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					column(x).remove(img.Gray16At(x, yy).Y)
+				}
+			}
+			if yy := y + radius; yy < bounds.Max.Y {
+				rows++
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					column(x).add(img.Gray16At(x, yy).Y)
+				}
+			}
+		}
+
+		var h histogram8
+		cols := 0
+		for x := rect.Min.X - radius; x <= rect.Min.X+radius; x++ {
+			if x >= bounds.Min.X && x < bounds.Max.X {
+				cols++
+				for i, c := range column(x) {
+					h[i] += c
+				}
+			}
+		}
+
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			dst.SetGray16(x, y, color.Gray16{Y: h.rank(rankIndex(percentile, rows*cols))})
+
+			if xx := x - radius; xx >= bounds.Min.X {
+				cols--
+				for i, c := range column(xx) {
+					h[i] -= c
+				}
+			}
+			if xx := x + radius + 1; xx < bounds.Max.X {
+				cols++
+				for i, c := range column(xx) {
+					h[i] += c
+				}
+			}
+		}
+	}
+}
+
+// Percentile is PercentileGray16 for an ImageReader, filtering each component
+// of its colors premultiplied by alpha, and alpha itself, and returning an
+// *image.NRGBA64 with the same bounds. An *image.Gray or *image.RGBA, whose
+// premultiplied components are already 8-bit, is filtered through views of
+// them, so that square windows take constant time per pixel. Other images,
+// including an *image.NRGBA whose premultiplied components need more than 8
+// bits, are filtered at 16-bit precision.
+func Percentile(radius int, shape Window, percentile float64, img ImageReader) *image.NRGBA64 {
+	switch src := img.(type) {
+	case *image.Gray:
+		return ConvertToNRGBA64(PercentileGray16(radius, shape, percentile, GrayChannel(src)))
+	case *image.RGBA:
+		return percentileRGBA(radius, shape, percentile, src)
+	}
+
+	return filterImage(img, true, func(c FloatChannel) *FloatGray {
+		return ChannelToFloat(PercentileGray16(radius, shape, percentile, FloatToGray16(c)))
+	})
+}
+
+// percentileRGBA implements Percentile for an *image.RGBA, filtering views of
+// its 8-bit premultiplied components.
+func percentileRGBA(radius int, shape Window, percentile float64, img *image.RGBA) *image.NRGBA64 {
+	var filtered [4]*image.Gray16
+	r, g, b, a := RGBAChannels(img)
+	for i, c := range []Channel{r, g, b, a} {
+		filtered[i] = PercentileGray16(radius, shape, percentile, c)
+	}
+
+	// Each percentile of a premultiplied component is at most the same
+	// percentile of alpha, so the results are still premultiplied colors.
+	dst := image.NewNRGBA64(img.Rect)
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				dst.Set(pt.X, pt.Y, color.RGBA64{
+					R: filtered[0].Gray16At(pt.X, pt.Y).Y,
+					G: filtered[1].Gray16At(pt.X, pt.Y).Y,
+					B: filtered[2].Gray16At(pt.X, pt.Y).Y,
+					A: filtered[3].Gray16At(pt.X, pt.Y).Y,
+				})
+			},
+		),
+	)(dst.Rect)
+	return dst
+}
+
+// Median is MedianGray16 for an ImageReader, as with Percentile.
+func Median(radius int, shape Window, img ImageReader) *image.NRGBA64 {
+	return Percentile(radius, shape, 50, img)
+}
+
+// Min is MinGray16 for an ImageReader, as with Percentile.
+func Min(radius int, shape Window, img ImageReader) *image.NRGBA64 {
+	return Percentile(radius, shape, 0, img)
+}
+
+// Max is MaxGray16 for an ImageReader, as with Percentile.
+func Max(radius int, shape Window, img ImageReader) *image.NRGBA64 {
+	return Percentile(radius, shape, 100, img)
+}
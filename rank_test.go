@@ -0,0 +1,166 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"sort"
+	"testing"
+)
+
+// percentileReference returns the given percentile of the values of a
+// Channel in a window around a point by sorting them.
+func percentileReference(img Channel, widths []int, percentile float64, pt image.Point) uint16 {
+	var values []int
+	radius := len(widths) / 2
+	for i, w := range widths {
+		for dx := -w; dx <= w; dx++ {
+			if p := image.Pt(pt.X+dx, pt.Y+i-radius); p.In(img.Bounds()) {
+				values = append(values, int(img.Gray16At(p.X, p.Y).Y))
+			}
+		}
+	}
+	sort.Ints(values)
+	return uint16(values[rankIndex(percentile, len(values))])
+}
+
+func TestPercentileGray16(t *testing.T) {
+	src := randomNRGBA64(image.Rect(-3, 5, 37, 38))
+	channels := map[string]Channel{
+		"8-bit":  GrayChannel(ConvertToGray(src)),
+		"16-bit": ConvertToGray16(src),
+	}
+	_, channels["green"], _, _ = NRGBAChannels(ConvertToNRGBA(src))
+
+	for name, img := range channels {
+		for _, shape := range []Window{WindowSquare, WindowCircle} {
+			for _, radius := range []int{0, 1, 3, 6} {
+				widths := shape.halfWidths(radius)
+				for _, percentile := range []float64{0, 30, 50, 100} {
+					dst := PercentileGray16(radius, shape, percentile, img)
+					if dst.Rect != img.Bounds() {
+						t.Errorf("%s percentile has bounds %v, expected %v", name, dst.Rect, img.Bounds())
+					}
+					AllPointsRP(
+						func(pt image.Point) {
+							expected := percentileReference(img, widths, percentile, pt)
+							if v := dst.Gray16At(pt.X, pt.Y).Y; v != expected {
+								t.Fatalf("%s percentile %v with shape %d and radius %d is %#04x at %v, expected %#04x",
+									name, percentile, shape, radius, v, pt, expected)
+							}
+						},
+					)(img.Bounds())
+				}
+			}
+		}
+	}
+}
+
+func TestWindowHalfWidths(t *testing.T) {
+	for shape, expected := range map[Window][]int{
+		WindowSquare: {2, 2, 2, 2, 2},
+		WindowCircle: {0, 1, 2, 1, 0},
+	} {
+		widths := shape.halfWidths(2)
+		for i := range expected {
+			if widths[i] != expected[i] {
+				t.Errorf("window %d has half-widths %v, expected %v", shape, widths, expected)
+				break
+			}
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+
+	// Isolated salt and pepper should be removed from a uniform image.
+	rect := image.Rect(0, 0, 30, 30)
+	src := image.NewNRGBA(rect)
+	AllPointsRP(
+		func(pt image.Point) {
+			c := color.NRGBA{R: 0x80, G: 0x40, B: 0x20, A: 0xff}
+			switch {
+			case pt.X%7 == 3 && pt.Y%5 == 2:
+				c = color.NRGBA{A: 0xff}
+			case pt.X%7 == 5 && pt.Y%5 == 4:
+				c = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			}
+			src.SetNRGBA(pt.X, pt.Y, c)
+		},
+	)(rect)
+
+	dst := Median(1, WindowSquare, src)
+	if dst.Rect != rect {
+		t.Errorf("median has bounds %v, expected %v", dst.Rect, rect)
+	}
+	AllPointsRP(
+		func(pt image.Point) {
+			if r, g, b, a := dst.At(pt.X, pt.Y).RGBA(); r != 0x8080 || g != 0x4040 || b != 0x2020 || a != 0xffff {
+				t.Errorf("median is %v at %v", dst.At(pt.X, pt.Y), pt)
+			}
+		},
+	)(rect)
+
+	// Min and Max should bound the median.
+	gray := ConvertToGray16(randomNRGBA64(rect))
+	lo, med, hi := MinGray16(2, WindowCircle, gray), MedianGray16(2, WindowCircle, gray), MaxGray16(2, WindowCircle, gray)
+	AllPointsRP(
+		func(pt image.Point) {
+			l, m, h := lo.Gray16At(pt.X, pt.Y).Y, med.Gray16At(pt.X, pt.Y).Y, hi.Gray16At(pt.X, pt.Y).Y
+			if l > m || m > h {
+				t.Errorf("min, median and max at %v are %#04x, %#04x and %#04x", pt, l, m, h)
+			}
+		},
+	)(rect)
+}
+
+func TestPercentile8Bit(t *testing.T) {
+	src := randomNRGBA64(image.Rect(-3, 5, 37, 38))
+	opaque := ConvertToNRGBA(src)
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 0xff
+	}
+
+	// Nearly transparent colors must keep their hue.
+	translucent := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	for i := 0; i < len(translucent.Pix); i += 4 {
+		copy(translucent.Pix[i:], []uint8{200, 100, 50, 1})
+	}
+
+	// The 8-bit views should give the same results as the generic path.
+	for name, img := range map[string]image.Image{
+		"Gray":              ConvertToGray(src),
+		"RGBA":              ConvertToRGBA(src),
+		"NRGBA":             opaque,
+		"random NRGBA":      ConvertToNRGBA(src),
+		"translucent NRGBA": translucent,
+	} {
+		for _, shape := range []Window{WindowSquare, WindowCircle} {
+			dst := Percentile(3, shape, 30, img)
+			expected := Percentile(3, shape, 30, opaqueImage{img})
+			if dst.Rect != expected.Rect || !bytes.Equal(dst.Pix, expected.Pix) {
+				t.Errorf("%s percentile with shape %d differs from the generic path", name, shape)
+			}
+		}
+	}
+
+	// A radius of zero shouldn't change the colors.
+	dst := Percentile(0, WindowSquare, 50, translucent)
+	if c, expected := dst.At(1, 1), color.NRGBA64Model.Convert(translucent.At(1, 1)); c != expected {
+		t.Errorf("percentile with radius 0 of %v is %v, expected %v", translucent.At(1, 1), c, expected)
+	}
+}
+
+func BenchmarkMedianGray8(b *testing.B) {
+	gray := GrayChannel(ConvertToGray(randomNRGBA64(image.Rect(0, 0, 256, 256))))
+	for i := 0; i < b.N; i++ {
+		MedianGray16(7, WindowSquare, gray)
+	}
+}
+
+func BenchmarkMedianGray16(b *testing.B) {
+	gray := ConvertToGray16(randomNRGBA64(image.Rect(0, 0, 256, 256)))
+	for i := 0; i < b.N; i++ {
+		MedianGray16(7, WindowSquare, gray)
+	}
+}
@@ -2,7 +2,6 @@ package imageutil
 
 import (
 	"image"
-	"runtime"
 	"sync"
 )
 
@@ -100,6 +99,18 @@ func ColumnsRP(width int, rp RP) RP {
 	}
 }
 
+// TilesRP returns a RP that devides an input rectangle into tiles of a given
+// width and height and calls the provided RP on each, row by row. The tiles
+// along the right and bottom edges will be any remainder and may not be of
+// the given width and height.
+func TilesRP(width, height int, rp RP) RP {
+	if width <= 0 || height <= 0 {
+		return noopRP
+	}
+
+	return RowsRP(height, ColumnsRP(width, rp))
+}
+
 // NRowsRP calls the given RP on each of n horizontal rectangles that span the
 // input rectangle.
 func NRowsRP(n int, rp RP) RP {
@@ -142,64 +153,31 @@ func NRectanglesRP(n int, rp RP) RP {
 	}
 }
 
-// QuickRowsRP calls the given RP concurrently on each of GOMAXPROCS
-// horizontal rectangles that span the input rectangle.
+// QuickRowsRP calls the given RP concurrently on each of the horizontal
+// rectangles that span the input rectangle, as scheduled by DefaultScheduler.
 func QuickRowsRP(rp RP) RP {
-	gomaxprocs := runtime.GOMAXPROCS(-1)
-	if gomaxprocs == 1 {
-		return rp
-	}
-
-	return func(rect image.Rectangle) {
-
-		// Create a new wait group and defer the wait.
-		var w sync.WaitGroup
-		defer w.Wait()
-
-		// Wrap the processor with an asynchronous processor.
-		rp = ConcurrentRP(&w, rp)
-
-		// Wrap the processor with a set count rows processor.
-		rp = NRowsRP(gomaxprocs, rp)
-
-		// Call the processor on the entire bounds.
-		rp(rect)
-	}
+	return DefaultScheduler.RowsRP(rp)
 }
 
-// QuickColumnsRP calls the given RP concurrently on each of GOMAXPROCS
-// vertical rectangles that span the input rectangle.
+// QuickColumnsRP calls the given RP concurrently on each of the vertical
+// rectangles that span the input rectangle, as scheduled by DefaultScheduler.
 func QuickColumnsRP(rp RP) RP {
-	gomaxprocs := runtime.GOMAXPROCS(-1)
-	if gomaxprocs == 1 {
-		return rp
-	}
-
-	return func(rect image.Rectangle) {
-
-		// Create a new wait group and defer the wait.
-		var w sync.WaitGroup
-		defer w.Wait()
-
-		// Wrap the processor with an asynchronous processor.
-		rp = ConcurrentRP(&w, rp)
-
-		// Wrap the processor with a set count columns processor.
-		rp = NColumnsRP(gomaxprocs, rp)
+	return DefaultScheduler.ColumnsRP(rp)
+}
 
-		// Call the processor on the entire bounds.
-		rp(rect)
+// QuickTilesRP calls the given RP concurrently on each tile of a given width
+// and height within the input rectangle, as scheduled by DefaultScheduler.
+// Remainders are handled as by TilesRP.
+func QuickTilesRP(width, height int, rp RP) RP {
+	if width <= 0 || height <= 0 {
+		return noopRP
 	}
+
+	return DefaultScheduler.tilesRP(image.Pt(width, height), rp)
 }
 
-// QuickRP calls the given RP concurrently on each of GOMAXPROCS horizontal or
-// vertical rectangles that span the input rectangle.
+// QuickRP calls the given RP concurrently on each of the tiles that make up
+// the input rectangle, as scheduled by DefaultScheduler.
 func QuickRP(rp RP) RP {
-	return func(rect image.Rectangle) {
-		if rect.Dx() > rect.Dy() {
-			QuickColumnsRP(rp)(rect)
-		} else {
-			QuickRowsRP(rp)(rect)
-		}
-	}
+	return DefaultScheduler.RP(rp)
 }
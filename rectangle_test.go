@@ -59,3 +59,52 @@ func TestPointsRP(t *testing.T) {
 		})(oneByOne)
 	}
 }
+
+func testTiles(t *testing.T, name string, rp func(int, int, RP) RP) {
+	rect := image.Rect(-3, 2, 100, 45)
+
+	var (
+		mu    sync.Mutex
+		tiles = make(map[image.Rectangle]bool)
+	)
+	rp(10, 20, func(tile image.Rectangle) {
+		mu.Lock()
+		tiles[tile] = true
+		mu.Unlock()
+	})(rect)
+
+	// 11 columns of 10 and 3 rows of 20, with remainders of 3 and 3.
+	if len(tiles) != 11*3 {
+		t.Errorf("%s processed %d tiles, expected %d", name, len(tiles), 11*3)
+	}
+
+	for tile := range tiles {
+		if !tile.In(rect) {
+			t.Errorf("%s processed %v outside of %v", name, tile, rect)
+		}
+
+		if tile.Min.X != rect.Min.X+(tile.Min.X-rect.Min.X)/10*10 || tile.Min.Y != rect.Min.Y+(tile.Min.Y-rect.Min.Y)/20*20 {
+			t.Errorf("%s processed misaligned tile %v", name, tile)
+		}
+
+		if (tile.Dx() != 10 && tile.Max.X != rect.Max.X) || (tile.Dy() != 20 && tile.Max.Y != rect.Max.Y) {
+			t.Errorf("%s processed a remainder tile %v away from the edges", name, tile)
+		}
+	}
+
+	rp(0, 1, func(image.Rectangle) {
+		t.Errorf("%s with zero width did not result in a noop", name)
+	})(rect)
+
+	rp(1, 0, func(image.Rectangle) {
+		t.Errorf("%s with zero height did not result in a noop", name)
+	})(rect)
+}
+
+func TestTilesRP(t *testing.T) {
+	testTiles(t, "TilesRP", TilesRP)
+}
+
+func TestQuickTilesRP(t *testing.T) {
+	testTiles(t, "QuickTilesRP", QuickTilesRP)
+}
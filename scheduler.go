@@ -0,0 +1,385 @@
+package imageutil
+
+import (
+	"context"
+	"image"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultTileSize is the tile size used by a Scheduler that was not given
+// one explicitly.
+var (
+	defaultTileSize = image.Pt(128, 128)
+)
+
+// DefaultScheduler is the Scheduler used by QuickRP and friends, and so by
+// every higher-level operation in this package. It may be replaced to tune
+// the parallelism of the package as a whole, but not while any operation is
+// running.
+var (
+	DefaultScheduler = NewScheduler(0, image.Point{})
+)
+
+// Scheduler divides an input rectangle into tiles and processes them using a
+// bounded pool of worker Go routines. Each worker starts with a contiguous
+// run of tiles and, once it has exhausted its own run, steals half of the
+// remaining tiles from another worker, so that uneven workloads don't leave
+// workers idle. A Scheduler is safe for concurrent use.
+type Scheduler struct {
+	workers  int
+	tileSize image.Point
+	progress Progress
+}
+
+// NewScheduler returns a Scheduler with the given number of workers and tile
+// size. A non-positive number of workers means GOMAXPROCS at the time of
+// processing, and a non-positive tile width or height means the default.
+func NewScheduler(workers int, tileSize image.Point) *Scheduler {
+	if tileSize.X <= 0 {
+		tileSize.X = defaultTileSize.X
+	}
+	if tileSize.Y <= 0 {
+		tileSize.Y = defaultTileSize.Y
+	}
+
+	return &Scheduler{
+		workers:  workers,
+		tileSize: tileSize,
+	}
+}
+
+// Workers returns the maximum number of worker Go routines the Scheduler will
+// use to process a rectangle.
+func (s *Scheduler) Workers() int {
+	if s.workers <= 0 {
+		return runtime.GOMAXPROCS(-1)
+	}
+	return s.workers
+}
+
+// TileSize returns the width and height of the tiles the Scheduler processes.
+func (s *Scheduler) TileSize() image.Point {
+	return s.tileSize
+}
+
+// WithProgress returns a copy of the Scheduler that reports the fraction of
+// the area of each input rectangle processed so far to the given Progress.
+// DefaultScheduler.WithProgress(p).RP(rp) is a QuickRP(rp) that reports
+// progress, and likewise for the other Quick helpers.
+func (s *Scheduler) WithProgress(p Progress) *Scheduler {
+	if p == nil {
+		return s
+	}
+
+	c := *s
+	c.progress = p
+	return &c
+}
+
+// RP returns a RP that calls the given RP concurrently on each tile of an
+// input rectangle. The tiles along the right and bottom edges will be any
+// remainder and may be smaller than the tile size.
+func (s *Scheduler) RP(rp RP) RP {
+	return s.tilesRP(s.tileSize, rp)
+}
+
+// RowsRP returns a RP that calls the given RP concurrently on each of the
+// horizontal rectangles, one tile high, that span an input rectangle.
+func (s *Scheduler) RowsRP(rp RP) RP {
+	return func(rect image.Rectangle) {
+		s.tilesRP(image.Pt(rect.Dx(), s.tileSize.Y), rp)(rect)
+	}
+}
+
+// ColumnsRP returns a RP that calls the given RP concurrently on each of the
+// vertical rectangles, one tile wide, that span an input rectangle.
+func (s *Scheduler) ColumnsRP(rp RP) RP {
+	return func(rect image.Rectangle) {
+		s.tilesRP(image.Pt(s.tileSize.X, rect.Dy()), rp)(rect)
+	}
+}
+
+// CRP is the CRP counterpart to RP. No further tiles are processed once the
+// context is done or the given CRP returns an error, and the first such error
+// is returned once all running tiles have finished.
+func (s *Scheduler) CRP(crp CRP) CRP {
+	return s.tilesCRP(s.tileSize, crp)
+}
+
+// RowsCRP is the CRP counterpart to RowsRP.
+func (s *Scheduler) RowsCRP(crp CRP) CRP {
+	return func(ctx context.Context, rect image.Rectangle) error {
+		return s.tilesCRP(image.Pt(rect.Dx(), s.tileSize.Y), crp)(ctx, rect)
+	}
+}
+
+// ColumnsCRP is the CRP counterpart to ColumnsRP.
+func (s *Scheduler) ColumnsCRP(crp CRP) CRP {
+	return func(ctx context.Context, rect image.Rectangle) error {
+		return s.tilesCRP(image.Pt(s.tileSize.X, rect.Dy()), crp)(ctx, rect)
+	}
+}
+
+// ERP is the ERP counterpart to RP. No further tiles are processed once the
+// given ERP returns an error, and the errors returned by any tiles that were
+// already running are joined.
+func (s *Scheduler) ERP(erp ERP) ERP {
+	return s.tilesERP(s.tileSize, erp)
+}
+
+// RowsERP is the ERP counterpart to RowsRP.
+func (s *Scheduler) RowsERP(erp ERP) ERP {
+	return func(rect image.Rectangle) error {
+		return s.tilesERP(image.Pt(rect.Dx(), s.tileSize.Y), erp)(rect)
+	}
+}
+
+// ColumnsERP is the ERP counterpart to ColumnsRP.
+func (s *Scheduler) ColumnsERP(erp ERP) ERP {
+	return func(rect image.Rectangle) error {
+		return s.tilesERP(image.Pt(s.tileSize.X, rect.Dy()), erp)(rect)
+	}
+}
+
+// tilesRP returns a RP that calls the given RP concurrently on each tile of
+// the given size within an input rectangle.
+func (s *Scheduler) tilesRP(size image.Point, rp RP) RP {
+	return func(rect image.Rectangle) {
+		s.run(rect, size, func(tile image.Rectangle) bool {
+			rp(tile)
+			return true
+		})
+	}
+}
+
+// tilesCRP returns a CRP that calls the given CRP concurrently on each tile of
+// the given size within an input rectangle.
+func (s *Scheduler) tilesCRP(size image.Point, crp CRP) CRP {
+	return func(ctx context.Context, rect image.Rectangle) error {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		s.run(rect, size, func(tile image.Rectangle) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			if err := crp(ctx, tile); err != nil {
+				cancel(err)
+				return false
+			}
+			return true
+		})
+
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return nil
+	}
+}
+
+// tilesERP returns an ERP that calls the given ERP concurrently on each tile
+// of the given size within an input rectangle.
+func (s *Scheduler) tilesERP(size image.Point, erp ERP) ERP {
+	return func(rect image.Rectangle) error {
+		var errs Errors
+		s.run(rect, size, func(tile image.Rectangle) bool {
+			if err := erp(tile); err != nil {
+				errs.Add(err)
+				return false
+			}
+			return true
+		})
+		return errs.Err()
+	}
+}
+
+// tileGrid describes the division of a rectangle into tiles of a given size,
+// numbered in row-major order.
+type tileGrid struct {
+	rect image.Rectangle
+	size image.Point
+	nx   int
+	n    int
+}
+
+// newTileGrid returns the tileGrid for the given rectangle and tile size.
+func newTileGrid(rect image.Rectangle, size image.Point) tileGrid {
+	if rect.Empty() || size.X <= 0 || size.Y <= 0 {
+		return tileGrid{}
+	}
+
+	nx := (rect.Dx() + size.X - 1) / size.X
+	ny := (rect.Dy() + size.Y - 1) / size.Y
+	return tileGrid{
+		rect: rect,
+		size: size,
+		nx:   nx,
+		n:    nx * ny,
+	}
+}
+
+// tile returns the i-th tile of the grid.
+func (g tileGrid) tile(i int) image.Rectangle {
+	pt := g.rect.Min.Add(image.Pt(i%g.nx*g.size.X, i/g.nx*g.size.Y))
+	return image.Rectangle{Min: pt, Max: pt.Add(g.size)}.Intersect(g.rect)
+}
+
+// span is a run of tile indexes belonging to a single worker.
+type span struct {
+	sync.Mutex
+	next, end int
+}
+
+// take removes and returns the first index in the span, if there is one.
+func (sp *span) take() (int, bool) {
+	sp.Lock()
+	defer sp.Unlock()
+
+	if sp.next >= sp.end {
+		return 0, false
+	}
+	i := sp.next
+	sp.next++
+	return i, true
+}
+
+// steal removes and returns the back half of the span.
+func (sp *span) steal() (next, end int) {
+	sp.Lock()
+	defer sp.Unlock()
+
+	k := (sp.end - sp.next + 1) / 2
+	sp.end -= k
+	return sp.end, sp.end + k
+}
+
+// run calls fn on every tile of the given size within rect until fn returns
+// false, at which point no further tiles are started. A panic within a worker
+// stops the remaining workers and is re-raised on the calling Go routine as a
+// *PanicError once they have finished.
+func (s *Scheduler) run(rect image.Rectangle, size image.Point, fn func(image.Rectangle) bool) {
+	grid := newTileGrid(rect, size)
+
+	// Report the area of each tile once it has been processed.
+	if s.progress != nil && grid.n > 0 {
+		c := &progressCounter{
+			total: rect.Dx() * rect.Dy(),
+			p:     s.progress,
+		}
+		next := fn
+		fn = func(tile image.Rectangle) bool {
+			ok := next(tile)
+			if ok {
+				c.add(tile.Dx() * tile.Dy())
+			}
+			return ok
+		}
+	}
+
+	workers := s.Workers()
+	if workers > grid.n {
+		workers = grid.n
+	}
+
+	// With a single worker there's no need to start any Go routines, but
+	// panics are still re-raised as a *PanicError.
+	if workers <= 1 {
+		var pe *PanicError
+		for i := 0; i < grid.n; i++ {
+			if !recoverTile(fn, grid.tile(i), func(e *PanicError) {
+				pe = e
+			}) {
+				break
+			}
+		}
+		if pe != nil {
+			panic(pe)
+		}
+		return
+	}
+
+	// Give each worker an equal contiguous run of tiles.
+	spans := make([]span, workers)
+	for w := range spans {
+		spans[w].next = grid.n * w / workers
+		spans[w].end = grid.n * (w + 1) / workers
+	}
+
+	var (
+		wg       sync.WaitGroup
+		stopped  int32
+		panicked sync.Once
+		pe       *PanicError
+	)
+
+	wg.Add(workers)
+	for w := range spans {
+		go func(w int) {
+			defer wg.Done()
+
+			own := &spans[w]
+			for atomic.LoadInt32(&stopped) == 0 {
+				i, ok := own.take()
+				if !ok {
+					if !refill(spans, w) {
+						return
+					}
+					continue
+				}
+
+				tile := grid.tile(i)
+				if !recoverTile(fn, tile, func(e *PanicError) {
+					panicked.Do(func() {
+						pe = e
+					})
+				}) {
+					atomic.StoreInt32(&stopped, 1)
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	// Re-raise the first panic on the calling Go routine.
+	if pe != nil {
+		panic(pe)
+	}
+}
+
+// recoverTile calls fn on the given tile, recovering any panic and passing it
+// to onPanic as a *PanicError, in which case it reports false. A panic that is
+// already a *PanicError, such as one re-raised by a nested Scheduler, is
+// passed on as it is.
+func recoverTile(fn func(image.Rectangle) bool, tile image.Rectangle, onPanic func(*PanicError)) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			pe, isPE := r.(*PanicError)
+			if !isPE {
+				pe = newPanicError(tile, r)
+			}
+			onPanic(pe)
+			ok = false
+		}
+	}()
+
+	return fn(tile)
+}
+
+// refill steals tiles from another worker on behalf of worker w, reporting
+// whether there were any left to steal.
+func refill(spans []span, w int) bool {
+	for k := 1; k < len(spans); k++ {
+		next, end := spans[(w+k)%len(spans)].steal()
+		if next < end {
+			own := &spans[w]
+			own.Lock()
+			own.next, own.end = next, end
+			own.Unlock()
+			return true
+		}
+	}
+	return false
+}
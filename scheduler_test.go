@@ -0,0 +1,116 @@
+package imageutil
+
+import (
+	"context"
+	"errors"
+	"image"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRP(t *testing.T) {
+	rect := image.Rect(-5, 3, 1000, 777)
+
+	for _, workers := range []int{1, 2, 3, 8} {
+		s := NewScheduler(workers, image.Pt(64, 32))
+
+		var (
+			mu     sync.Mutex
+			counts = make(map[image.Point]int)
+			active int64
+			peak   int64
+		)
+
+		s.RP(func(tile image.Rectangle) {
+			if n := atomic.AddInt64(&active, 1); n > atomic.LoadInt64(&peak) {
+				atomic.StoreInt64(&peak, n)
+			}
+			defer atomic.AddInt64(&active, -1)
+
+			if tile.Dx() > 64 || tile.Dy() > 32 {
+				t.Error("tile larger than the tile size:", tile)
+			}
+
+			// Make the workload uneven.
+			if tile.Min.X < 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			mu.Lock()
+			AllPointsRP(func(pt image.Point) {
+				counts[pt]++
+			})(tile)
+			mu.Unlock()
+		})(rect)
+
+		if len(counts) != rect.Dx()*rect.Dy() {
+			t.Errorf("%d workers processed %d points, expected %d", workers, len(counts), rect.Dx()*rect.Dy())
+		}
+
+		for pt, n := range counts {
+			if !pt.In(rect) || n != 1 {
+				t.Fatalf("%d workers processed %v %d times", workers, pt, n)
+			}
+		}
+
+		if peak > int64(workers) {
+			t.Errorf("%d workers ran %d tiles at once", workers, peak)
+		}
+	}
+}
+
+func TestSchedulerRowsRP(t *testing.T) {
+	rect := image.Rect(0, 0, 300, 100)
+
+	var rows int64
+	NewScheduler(4, image.Pt(10, 30)).RowsRP(func(row image.Rectangle) {
+		atomic.AddInt64(&rows, 1)
+		if row.Min.X != rect.Min.X || row.Max.X != rect.Max.X {
+			t.Error("row does not span the rectangle:", row)
+		}
+
+		// The last row is the remainder.
+		if row.Dy() != 30 && !(row.Dy() == 10 && row.Max.Y == rect.Max.Y) {
+			t.Error("unexpected row:", row)
+		}
+	})(rect)
+
+	if rows != 4 {
+		t.Error("expected 4 rows, found", rows)
+	}
+}
+
+func TestSchedulerCRPError(t *testing.T) {
+	testErr := errors.New("test error")
+
+	var n int64
+	err := NewScheduler(4, image.Pt(1, 1)).CRP(func(ctx context.Context, rect image.Rectangle) error {
+		if atomic.AddInt64(&n, 1) == 10 {
+			return testErr
+		}
+		return ctx.Err()
+	})(context.Background(), image.Rect(0, 0, 100, 100))
+
+	if err != testErr {
+		t.Error("expected the test error, found", err)
+	}
+
+	if n >= 100*100 {
+		t.Error("all tiles were processed despite an error")
+	}
+}
+
+func BenchmarkSchedulerUneven(b *testing.B) {
+	rect := image.Rect(0, 0, 1000, 1000)
+	rp := AllPointsRP(func(pt image.Point) {
+		if pt.Y < 250 {
+			time.Sleep(0)
+		}
+	})
+
+	for i := 0; i < b.N; i++ {
+		QuickRP(rp)(rect)
+	}
+}
@@ -0,0 +1,141 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// UnsharpMaskFloat concurrently sharpens a FloatChannel by adding the
+// difference between it and a Gaussian blur of it reaching radius values
+// either side of each value, multiplied by amount, returning a *FloatGray
+// with the same bounds. The blur has a standard deviation of a third of the
+// radius, as ExactGaussianBlurFloat's reaches three standard deviations.
+// Values whose difference from the blur is less than threshold, on the same
+// scale of 0 to 1 as the values, are left unchanged, so that noise in smooth
+// regions isn't amplified.
+func UnsharpMaskFloat(radius, amount, threshold float64, img FloatChannel) *FloatGray {
+	return unsharpMaskFloat(DefaultScheduler, radius, amount, threshold, img)
+}
+
+// unsharpMaskFloat implements UnsharpMaskFloat using the given Scheduler.
+func unsharpMaskFloat(s *Scheduler, radius, amount, threshold float64, img FloatChannel) *FloatGray {
+	blurred := exactGaussianBlurFloat(s, radius/3, img)
+	dst := NewFloatGray(img.Bounds())
+	s.RP(
+		AllPointsRP(
+			func(pt image.Point) {
+				v := float64(img.FloatAt(pt.X, pt.Y))
+				if d := v - float64(blurred.FloatAt(pt.X, pt.Y)); math.Abs(d) >= threshold {
+					v += amount * d
+				}
+				dst.SetFloat(pt.X, pt.Y, float32(v))
+			},
+		),
+	)(dst.Rect)
+	return dst
+}
+
+// UnsharpMaskGray16 is UnsharpMaskFloat for a Channel, rounding and clamping
+// the result to an *image.Gray16. The threshold is still on the scale of 0 to
+// 1, where 1 is 0xffff.
+func UnsharpMaskGray16(radius, amount, threshold float64, img Channel) *image.Gray16 {
+	return FloatToGray16(UnsharpMaskFloat(radius, amount, threshold, ChannelToFloat(img)))
+}
+
+// UnsharpMask is UnsharpMaskFloat for an ImageReader, such as an
+// *image.NRGBA64, sharpening its colors premultiplied by alpha but not alpha
+// itself, and returning an *image.NRGBA64 with the same bounds. The radius is
+// in pixels, with a blur of a third of it as its standard deviation, and the
+// threshold is on the scale of 0 to 1, where 1 is the full 0xffff range of
+// each component.
+func UnsharpMask(radius, amount, threshold float64, img ImageReader) *image.NRGBA64 {
+	return filterImage(img, false, func(c FloatChannel) *FloatGray {
+		return UnsharpMaskFloat(radius, amount, threshold, c)
+	})
+}
+
+// UnsharpMaskLuminance is UnsharpMask that only sharpens the luminance of the
+// colors, avoiding the color fringes that sharpening each component
+// separately can cause.
+func UnsharpMaskLuminance(radius, amount, threshold float64, img ImageReader) *image.NRGBA64 {
+	return sharpenLuminance(img, func(c FloatChannel) *FloatGray {
+		return UnsharpMaskFloat(radius, amount, threshold, c)
+	})
+}
+
+// highPassKernel returns a Kernel that adds the difference between each value
+// and the average of its four nearest neighbours, multiplied by amount.
+func highPassKernel(amount float64) Kernel {
+	return NewKernel([][]float64{
+		{0, -amount, 0},
+		{-amount, 1 + 4*amount, -amount},
+		{0, -amount, 0},
+	})
+}
+
+// HighPassSharpenFloat concurrently sharpens a FloatChannel by convolving it
+// with a 3x3 high-pass kernel, adding the difference between each value and
+// its four nearest neighbours multiplied by amount, and returns a *FloatGray
+// with the same bounds. The values at the edges are extended beyond them.
+func HighPassSharpenFloat(amount float64, img FloatChannel) *FloatGray {
+	return ConvolveFloat(img, highPassKernel(amount), ConvolveOptions{Border: BorderClamp})
+}
+
+// HighPassSharpenGray16 is HighPassSharpenFloat for a Channel, rounding and
+// clamping the result to an *image.Gray16.
+func HighPassSharpenGray16(amount float64, img Channel) *image.Gray16 {
+	return FloatToGray16(HighPassSharpenFloat(amount, ChannelToFloat(img)))
+}
+
+// HighPassSharpen is HighPassSharpenFloat for an ImageReader, such as an
+// *image.NRGBA64, sharpening its colors premultiplied by alpha but not alpha
+// itself, and returning an *image.NRGBA64 with the same bounds.
+func HighPassSharpen(amount float64, img ImageReader) *image.NRGBA64 {
+	return filterImage(img, false, func(c FloatChannel) *FloatGray {
+		return HighPassSharpenFloat(amount, c)
+	})
+}
+
+// HighPassSharpenLuminance is HighPassSharpen that only sharpens the
+// luminance of the colors, as with UnsharpMaskLuminance.
+func HighPassSharpenLuminance(amount float64, img ImageReader) *image.NRGBA64 {
+	return sharpenLuminance(img, func(c FloatChannel) *FloatGray {
+		return HighPassSharpenFloat(amount, c)
+	})
+}
+
+// sharpenLuminance sharpens the luminance of the colors of an ImageReader,
+// premultiplied by alpha, and adds the change in it to each of their red,
+// green and blue components, keeping the differences between them. It
+// returns the result as an *image.NRGBA64.
+func sharpenLuminance(img ImageReader, sharpen func(FloatChannel) *FloatGray) *image.NRGBA64 {
+	bounds := img.Bounds()
+	luminance := ChannelToFloat(ConvertToGray16(img))
+	sharpened := sharpen(luminance)
+
+	dst := image.NewNRGBA64(bounds)
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				r, g, b, a := img.At(pt.X, pt.Y).RGBA()
+				d := float64(sharpened.FloatAt(pt.X, pt.Y)-luminance.FloatAt(pt.X, pt.Y)) * math.MaxUint16
+				premultiplied := func(c uint32) uint16 {
+					if v := clampUint16(float64(c) + d); uint32(v) < a {
+						return v
+					}
+					return uint16(a)
+				}
+
+				dst.Set(pt.X, pt.Y, color.RGBA64{
+					R: premultiplied(r),
+					G: premultiplied(g),
+					B: premultiplied(b),
+					A: uint16(a),
+				})
+			},
+		),
+	)(bounds)
+
+	return dst
+}
@@ -0,0 +1,121 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestSharpenGray16(t *testing.T) {
+	rect := image.Rect(-5, 3, 25, 23)
+	step := stepGray16(rect, 0x4000, 0xc000, func(pt image.Point) bool { return pt.X >= 10 })
+
+	for name, sharpen := range map[string]func(Channel) *image.Gray16{
+		"unsharp mask": func(c Channel) *image.Gray16 { return UnsharpMaskGray16(6, 1, 0, c) },
+		"high-pass":    func(c Channel) *image.Gray16 { return HighPassSharpenGray16(1, c) },
+	} {
+		dst := sharpen(step)
+		if dst.Rect != rect {
+			t.Errorf("%s has bounds %v, expected %v", name, dst.Rect, rect)
+		}
+
+		// Edges should overshoot on both sides, and uniform regions shouldn't
+		// change.
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			if v := dst.Gray16At(9, y).Y; v >= 0x4000 {
+				t.Errorf("%s on the dark side is %#04x at %v", name, v, image.Pt(9, y))
+			}
+			if v := dst.Gray16At(10, y).Y; v <= 0xc000 {
+				t.Errorf("%s on the light side is %#04x at %v", name, v, image.Pt(10, y))
+			}
+			if v := dst.Gray16At(rect.Min.X, y).Y; v != 0x4000 {
+				t.Errorf("%s of a uniform region is %#04x at %v", name, v, image.Pt(rect.Min.X, y))
+			}
+		}
+	}
+
+	// The blur's standard deviation is a third of the radius.
+	f := ChannelToFloat(step)
+	sharpened, blurred := UnsharpMaskFloat(6, 1, 0, f), ExactGaussianBlurFloat(2, f)
+	AllPointsRP(
+		func(pt image.Point) {
+			v, b := sharpened.FloatAt(pt.X, pt.Y), blurred.FloatAt(pt.X, pt.Y)
+			if expected := 2*f.FloatAt(pt.X, pt.Y) - b; math.Abs(float64(v-expected)) > 1e-6 {
+				t.Errorf("unsharp mask is %v at %v, expected %v", v, pt, expected)
+			}
+		},
+	)(rect)
+
+	// Differences below the threshold are left alone.
+	noise := image.NewGray16(rect)
+	AllPointsRP(
+		func(pt image.Point) {
+			noise.SetGray16(pt.X, pt.Y, color.Gray16{Y: 0x8000 + uint16((pt.X*7+pt.Y*3)%5)*0x80})
+		},
+	)(rect)
+	if dst := UnsharpMaskGray16(6, 1, 0.05, noise); !bytes.Equal(dst.Pix, noise.Pix) {
+		t.Error("unsharp mask sharpened differences below the threshold")
+	}
+	if dst := HighPassSharpenGray16(0, noise); !bytes.Equal(dst.Pix, noise.Pix) {
+		t.Error("high-pass sharpen with no amount changed the channel")
+	}
+}
+
+func TestSharpenLuminance(t *testing.T) {
+	rect := image.Rect(0, 0, 20, 10)
+	src := image.NewNRGBA64(rect)
+	AllPointsRP(
+		func(pt image.Point) {
+			if pt.X < 10 {
+				src.SetNRGBA64(pt.X, pt.Y, color.NRGBA64{R: 0x6000, G: 0x5000, B: 0x4000, A: 0xffff})
+			} else {
+				src.SetNRGBA64(pt.X, pt.Y, color.NRGBA64{R: 0xa000, G: 0x9000, B: 0x8000, A: 0xffff})
+			}
+		},
+	)(rect)
+
+	for name, sharpen := range map[string]func(ImageReader) *image.NRGBA64{
+		"unsharp mask": func(img ImageReader) *image.NRGBA64 { return UnsharpMaskLuminance(4.5, 0.5, 0, img) },
+		"high-pass":    func(img ImageReader) *image.NRGBA64 { return HighPassSharpenLuminance(0.5, img) },
+	} {
+		dst := sharpen(src)
+		if dst.Rect != rect {
+			t.Errorf("%s has bounds %v, expected %v", name, dst.Rect, rect)
+		}
+		if c := dst.NRGBA64At(10, 5); c.R <= 0xa000 {
+			t.Errorf("%s didn't sharpen the edge, giving %v", name, c)
+		}
+
+		// The differences between the components shouldn't change.
+		AllPointsRP(
+			func(pt image.Point) {
+				c := dst.NRGBA64At(pt.X, pt.Y)
+				rg, gb := int(c.R)-int(c.G), int(c.G)-int(c.B)
+				if rg < 0xfff || rg > 0x1001 || gb < 0xfff || gb > 0x1001 || c.A != 0xffff {
+					t.Errorf("%s changed the color at %v to %v", name, pt, c)
+				}
+			},
+		)(rect)
+	}
+
+	// Sharpening each component doesn't touch alpha.
+	translucent := image.NewNRGBA64(rect)
+	copy(translucent.Pix, src.Pix)
+	for i := 6; i < len(translucent.Pix); i += 8 {
+		translucent.Pix[i], translucent.Pix[i+1] = 0x80, 0x00
+	}
+	for name, dst := range map[string]*image.NRGBA64{
+		"unsharp mask": UnsharpMask(4.5, 1, 0, translucent),
+		"high-pass":    HighPassSharpen(1, translucent),
+	} {
+		AllPointsRP(
+			func(pt image.Point) {
+				if a := dst.NRGBA64At(pt.X, pt.Y).A; a != 0x8000 {
+					t.Errorf("%s changed alpha at %v to %#04x", name, pt, a)
+				}
+			},
+		)(rect)
+	}
+}
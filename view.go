@@ -0,0 +1,167 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+)
+
+// WritableChannel is a Channel that also provides a method for setting
+// color.Gray16 values at given coordinates. The standard library's
+// image.Gray16 implements WritableChannel.
+type WritableChannel interface {
+	Channel
+	SetGray16(x, y int, c color.Gray16)
+}
+
+// pixView is a WritableChannel that reads and writes a single component of
+// the pixels of a parent image straight from and to its Pix slice. Writing to
+// the components of alpha-premultiplied images may leave colors that are
+// invalid, with a component greater than alpha.
+type pixView struct {
+	rect   image.Rectangle
+	pix    []uint8
+	stride int
+
+	// bpp is the number of bytes per pixel and offset is the number of bytes
+	// from the start of a pixel to the component.
+	bpp, offset int
+
+	// wide is whether the component is a big-endian uint16 rather than a
+	// uint8.
+	wide bool
+}
+
+// i returns the index of the component of the pixel at the given coordinates
+// in the Pix slice.
+func (v *pixView) i(x, y int) int {
+	return (y-v.rect.Min.Y)*v.stride + (x-v.rect.Min.X)*v.bpp + v.offset
+}
+
+func (v *pixView) Bounds() image.Rectangle {
+	return v.rect
+}
+
+func (v *pixView) ColorModel() color.Model {
+	return color.Gray16Model
+}
+
+func (v *pixView) At(x, y int) color.Color {
+	return v.Gray16At(x, y)
+}
+
+func (v *pixView) Gray16At(x, y int) color.Gray16 {
+	if !(image.Point{X: x, Y: y}.In(v.rect)) {
+		return color.Gray16{}
+	}
+
+	i := v.i(x, y)
+	if v.wide {
+		return color.Gray16{Y: get16(v.pix[i:])}
+	}
+	return color.Gray16{Y: uint16(v.pix[i]) * 0x101}
+}
+
+func (v *pixView) Set(x, y int, c color.Color) {
+	v.SetGray16(x, y, color.Gray16Model.Convert(c).(color.Gray16))
+}
+
+func (v *pixView) SetGray16(x, y int, c color.Gray16) {
+	if !(image.Point{X: x, Y: y}.In(v.rect)) {
+		return
+	}
+
+	i := v.i(x, y)
+	if v.wide {
+		put16(v.pix[i:], c.Y)
+	} else {
+		v.pix[i] = uint8(c.Y >> 8)
+	}
+}
+
+// pixViews returns a WritableChannel for each of the n components of the
+// pixels of a parent image.
+func pixViews(rect image.Rectangle, pix []uint8, stride, n int, wide bool) []WritableChannel {
+	size := 1
+	if wide {
+		size = 2
+	}
+
+	views := make([]WritableChannel, n)
+	for i := range views {
+		views[i] = &pixView{
+			rect:   rect,
+			pix:    pix,
+			stride: stride,
+			bpp:    n * size,
+			offset: i * size,
+			wide:   wide,
+		}
+	}
+	return views
+}
+
+// NRGBA64Channels returns red, green, blue and alpha WritableChannels that
+// read and write the pixels of an *image.NRGBA64 in place.
+func NRGBA64Channels(img *image.NRGBA64) (r, g, b, a WritableChannel) {
+	v := pixViews(img.Rect, img.Pix, img.Stride, 4, true)
+	return v[0], v[1], v[2], v[3]
+}
+
+// RGBA64Channels returns alpha-premultiplied red, green, blue and alpha
+// WritableChannels that read and write the pixels of an *image.RGBA64 in
+// place.
+func RGBA64Channels(img *image.RGBA64) (r, g, b, a WritableChannel) {
+	v := pixViews(img.Rect, img.Pix, img.Stride, 4, true)
+	return v[0], v[1], v[2], v[3]
+}
+
+// NRGBAChannels returns red, green, blue and alpha WritableChannels that read
+// and write the pixels of an *image.NRGBA in place, at 8-bit precision.
+func NRGBAChannels(img *image.NRGBA) (r, g, b, a WritableChannel) {
+	v := pixViews(img.Rect, img.Pix, img.Stride, 4, false)
+	return v[0], v[1], v[2], v[3]
+}
+
+// RGBAChannels returns alpha-premultiplied red, green, blue and alpha
+// WritableChannels that read and write the pixels of an *image.RGBA in place,
+// at 8-bit precision.
+func RGBAChannels(img *image.RGBA) (r, g, b, a WritableChannel) {
+	v := pixViews(img.Rect, img.Pix, img.Stride, 4, false)
+	return v[0], v[1], v[2], v[3]
+}
+
+// CMYKChannels returns cyan, magenta, yellow and black WritableChannels that
+// read and write the pixels of an *image.CMYK in place, at 8-bit precision.
+func CMYKChannels(img *image.CMYK) (c, m, y, k WritableChannel) {
+	v := pixViews(img.Rect, img.Pix, img.Stride, 4, false)
+	return v[0], v[1], v[2], v[3]
+}
+
+// GrayChannel returns a WritableChannel that reads and writes the pixels of
+// an *image.Gray in place, at 8-bit precision.
+func GrayChannel(img *image.Gray) WritableChannel {
+	return pixViews(img.Rect, img.Pix, img.Stride, 1, false)[0]
+}
+
+// AlphaChannel returns a WritableChannel that reads and writes the pixels of
+// an *image.Alpha in place, at 8-bit precision.
+func AlphaChannel(img *image.Alpha) WritableChannel {
+	return pixViews(img.Rect, img.Pix, img.Stride, 1, false)[0]
+}
+
+// Alpha16Channel returns a WritableChannel that reads and writes the pixels
+// of an *image.Alpha16 in place.
+func Alpha16Channel(img *image.Alpha16) WritableChannel {
+	return pixViews(img.Rect, img.Pix, img.Stride, 1, true)[0]
+}
+
+// YCbCrChannels returns luma and chroma WritableChannels, laid out as by
+// YCbCrToChannels, that read and write the planes of an *image.YCbCr in place,
+// at 8-bit precision.
+func YCbCrChannels(img *image.YCbCr) (y, cb, cr WritableChannel) {
+	rect := chromaRect(img.Rect, img.SubsampleRatio)
+	y = pixViews(img.Rect, img.Y, img.YStride, 1, false)[0]
+	cb = pixViews(rect, img.Cb, img.CStride, 1, false)[0]
+	cr = pixViews(rect, img.Cr, img.CStride, 1, false)[0]
+	return
+}
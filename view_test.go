@@ -0,0 +1,112 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testView checks that a WritableChannel reads and writes the given
+// component of each pixel of its parent image.
+func testView(t *testing.T, name string, v WritableChannel, parent ImageReader, component func(x, y int) uint16, wide bool) {
+	if v.Bounds() != parent.Bounds() {
+		t.Errorf("%s view has bounds %v, expected %v", name, v.Bounds(), parent.Bounds())
+	}
+
+	AllPointsRP(
+		func(pt image.Point) {
+			if got, expected := v.Gray16At(pt.X, pt.Y).Y, component(pt.X, pt.Y); got != expected {
+				t.Errorf("%s view reads %#04x at %v, expected %#04x", name, got, pt, expected)
+			}
+
+			value := uint16(pt.X*0x123 + pt.Y*0x4567)
+			if !wide {
+				value = value >> 8 * 0x101
+			}
+			v.SetGray16(pt.X, pt.Y, color.Gray16{Y: value})
+			if got := component(pt.X, pt.Y); got != value {
+				t.Errorf("%s view wrote %#04x at %v, expected %#04x", name, got, pt, value)
+			}
+		},
+	)(parent.Bounds())
+
+	// Coordinates outside the bounds read as zero and are ignored.
+	outside := v.Bounds().Max
+	if c := v.Gray16At(outside.X, outside.Y); c.Y != 0 {
+		t.Errorf("%s view reads %v outside its bounds", name, c)
+	}
+	v.SetGray16(outside.X, outside.Y, color.Gray16{Y: 0xffff})
+}
+
+func TestViews(t *testing.T) {
+	src := randomNRGBA64(copyTestRect).(*image.NRGBA64)
+	sub := src.SubImage(image.Rect(2, 7, 51, 60)).(*image.NRGBA64)
+	r, g, b, a := NRGBA64Channels(sub)
+	testView(t, "NRGBA64 red", r, sub, func(x, y int) uint16 { return sub.NRGBA64At(x, y).R }, true)
+	testView(t, "NRGBA64 green", g, sub, func(x, y int) uint16 { return sub.NRGBA64At(x, y).G }, true)
+	testView(t, "NRGBA64 blue", b, sub, func(x, y int) uint16 { return sub.NRGBA64At(x, y).B }, true)
+	testView(t, "NRGBA64 alpha", a, sub, func(x, y int) uint16 { return sub.NRGBA64At(x, y).A }, true)
+
+	rgba := ConvertToRGBA(randomNRGBA64(copyTestRect))
+	r, g, b, a = RGBAChannels(rgba)
+	testView(t, "RGBA red", r, rgba, func(x, y int) uint16 { return uint16(rgba.RGBAAt(x, y).R) * 0x101 }, false)
+	testView(t, "RGBA green", g, rgba, func(x, y int) uint16 { return uint16(rgba.RGBAAt(x, y).G) * 0x101 }, false)
+	testView(t, "RGBA blue", b, rgba, func(x, y int) uint16 { return uint16(rgba.RGBAAt(x, y).B) * 0x101 }, false)
+	testView(t, "RGBA alpha", a, rgba, func(x, y int) uint16 { return uint16(rgba.RGBAAt(x, y).A) * 0x101 }, false)
+
+	rgba64 := ConvertToRGBA64(randomNRGBA64(copyTestRect))
+	_, _, _, a = RGBA64Channels(rgba64)
+	testView(t, "RGBA64 alpha", a, rgba64, func(x, y int) uint16 { return rgba64.RGBA64At(x, y).A }, true)
+
+	nrgba := ConvertToNRGBA(randomNRGBA64(copyTestRect))
+	_, g, _, _ = NRGBAChannels(nrgba)
+	testView(t, "NRGBA green", g, nrgba, func(x, y int) uint16 { return uint16(nrgba.NRGBAAt(x, y).G) * 0x101 }, false)
+
+	cmyk := image.NewCMYK(copyTestRect)
+	Copy(cmyk, randomNRGBA64(copyTestRect))
+	_, _, _, k := CMYKChannels(cmyk)
+	testView(t, "CMYK black", k, cmyk, func(x, y int) uint16 { return uint16(cmyk.CMYKAt(x, y).K) * 0x101 }, false)
+
+	gray := ConvertToGray(randomNRGBA64(copyTestRect))
+	testView(t, "Gray", GrayChannel(gray), gray, func(x, y int) uint16 { return uint16(gray.GrayAt(x, y).Y) * 0x101 }, false)
+
+	alpha := ConvertToAlpha(randomNRGBA64(copyTestRect))
+	testView(t, "Alpha", AlphaChannel(alpha), alpha, func(x, y int) uint16 { return uint16(alpha.AlphaAt(x, y).A) * 0x101 }, false)
+
+	alpha16 := ConvertToAlpha16(randomNRGBA64(copyTestRect))
+	testView(t, "Alpha16", Alpha16Channel(alpha16), alpha16, func(x, y int) uint16 { return alpha16.Alpha16At(x, y).A }, true)
+
+	ycbcr := randomYCbCr(copyTestRect, image.YCbCrSubsampleRatio444)
+	y, cb, _ := YCbCrChannels(ycbcr)
+	testView(t, "YCbCr luma", y, ycbcr, func(x, y int) uint16 { return uint16(ycbcr.YCbCrAt(x, y).Y) * 0x101 }, false)
+	testView(t, "YCbCr blue chroma", cb, ycbcr, func(x, y int) uint16 { return uint16(ycbcr.YCbCrAt(x, y).Cb) * 0x101 }, false)
+}
+
+func TestViewsInPlace(t *testing.T) {
+	src := randomNRGBA64(copyTestRect).(*image.NRGBA64)
+	expected := EdgesNRGBA64(3, src)
+
+	// Filtering the views of a copy and writing the results back through them
+	// should match filtering into a new image.
+	dst := image.NewNRGBA64(src.Rect)
+	Copy(dst, src)
+	r, g, b, _ := NRGBA64ToChannels(dst)
+	for _, c := range []Channel{r, g, b} {
+		w, ok := c.(WritableChannel)
+		if !ok {
+			t.Fatalf("NRGBA64ToChannels returned a %T, which isn't a WritableChannel", c)
+		}
+
+		edges := EdgesGray16(3, w)
+		AllPointsRP(
+			func(pt image.Point) {
+				w.SetGray16(pt.X, pt.Y, edges.Gray16At(pt.X, pt.Y))
+			},
+		)(w.Bounds())
+	}
+
+	if !bytes.Equal(dst.Pix, expected.Pix) {
+		t.Error("filtering channel views in place doesn't match EdgesNRGBA64")
+	}
+}
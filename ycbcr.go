@@ -0,0 +1,192 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+)
+
+// YCbCr is an *image.YCbCr that implements ImageReadWriter. Under chroma
+// subsampling, setting a pixel also sets the chroma of every pixel that
+// shares its chroma sample.
+type YCbCr struct {
+	*image.YCbCr
+}
+
+// Set sets the luma of the pixel at the given coordinates and the chroma of
+// its chroma sample.
+func (p *YCbCr) Set(x, y int, c color.Color) {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return
+	}
+
+	c1 := color.YCbCrModel.Convert(c).(color.YCbCr)
+	p.Y[p.YOffset(x, y)] = c1.Y
+	ci := p.COffset(x, y)
+	p.Cb[ci] = c1.Cb
+	p.Cr[ci] = c1.Cr
+}
+
+// NYCbCrA is an *image.NYCbCrA that implements ImageReadWriter. Under chroma
+// subsampling, setting a pixel also sets the chroma of every pixel that
+// shares its chroma sample.
+type NYCbCrA struct {
+	*image.NYCbCrA
+}
+
+// Set sets the luma and alpha of the pixel at the given coordinates and the
+// chroma of its chroma sample.
+func (p *NYCbCrA) Set(x, y int, c color.Color) {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return
+	}
+
+	c1 := color.NYCbCrAModel.Convert(c).(color.NYCbCrA)
+	p.Y[p.YOffset(x, y)] = c1.Y
+	p.A[p.AOffset(x, y)] = c1.A
+	ci := p.COffset(x, y)
+	p.Cb[ci] = c1.Cb
+	p.Cr[ci] = c1.Cr
+}
+
+// ConvertToYCbCr returns a *YCbCr instance with the given chroma subsampling
+// ratio, either by wrapping the given ImageReader if it is an *image.YCbCr
+// with that ratio or by concurrently converting its colors. Each chroma
+// sample of a new instance is the average of the chroma of the pixels that
+// share it.
+func ConvertToYCbCr(src ImageReader, ratio image.YCbCrSubsampleRatio) *YCbCr {
+	switch src := src.(type) {
+	case *YCbCr:
+		if src.SubsampleRatio == ratio {
+			return src
+		}
+	case *image.YCbCr:
+		if src.SubsampleRatio == ratio {
+			return &YCbCr{src}
+		}
+	}
+
+	dst := image.NewYCbCr(src.Bounds(), ratio)
+	convertToYCbCr(dst, nil, src)
+	return &YCbCr{dst}
+}
+
+// ConvertToNYCbCrA is the *NYCbCrA counterpart to ConvertToYCbCr.
+func ConvertToNYCbCrA(src ImageReader, ratio image.YCbCrSubsampleRatio) *NYCbCrA {
+	switch src := src.(type) {
+	case *NYCbCrA:
+		if src.SubsampleRatio == ratio {
+			return src
+		}
+	case *image.NYCbCrA:
+		if src.SubsampleRatio == ratio {
+			return &NYCbCrA{src}
+		}
+	}
+
+	dst := image.NewNYCbCrA(src.Bounds(), ratio)
+	convertToYCbCr(&dst.YCbCr, dst, src)
+	return &NYCbCrA{dst}
+}
+
+// convertToYCbCr concurrently sets the luma of dst, and the alpha of alpha if
+// it isn't nil, to those of src, and sets each chroma sample of dst to the
+// average chroma of the pixels of src that share it.
+func convertToYCbCr(dst *image.YCbCr, alpha *image.NYCbCrA, src ImageReader) {
+	bounds := dst.Rect
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return
+	}
+
+	// Set the luma and alpha of each pixel, holding on to the full resolution
+	// chroma.
+	cb := make([]uint8, w*h)
+	cr := make([]uint8, w*h)
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				var c color.YCbCr
+				if alpha != nil {
+					c1 := color.NYCbCrAModel.Convert(src.At(pt.X, pt.Y)).(color.NYCbCrA)
+					alpha.A[alpha.AOffset(pt.X, pt.Y)] = c1.A
+					c = c1.YCbCr
+				} else {
+					c = color.YCbCrModel.Convert(src.At(pt.X, pt.Y)).(color.YCbCr)
+				}
+
+				dst.Y[dst.YOffset(pt.X, pt.Y)] = c.Y
+				i := (pt.Y-bounds.Min.Y)*w + (pt.X - bounds.Min.X)
+				cb[i] = c.Cb
+				cr[i] = c.Cr
+			},
+		),
+	)(bounds)
+
+	// Group the columns and rows of pixels by the chroma sample they share,
+	// using the same offsets as COffset.
+	cw := dst.CStride
+	ch := len(dst.Cb) / cw
+	columns := make([][]int, cw)
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		ci := dst.COffset(x, bounds.Min.Y) - dst.COffset(bounds.Min.X, bounds.Min.Y)
+		columns[ci] = append(columns[ci], x-bounds.Min.X)
+	}
+	rows := make([][]int, ch)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		ci := (dst.COffset(bounds.Min.X, y) - dst.COffset(bounds.Min.X, bounds.Min.Y)) / cw
+		rows[ci] = append(rows[ci], y-bounds.Min.Y)
+	}
+
+	// Average the chroma of each sample.
+	QuickRP(
+		AllPointsRP(
+			func(pt image.Point) {
+				var sumCb, sumCr, n int
+				for _, y := range rows[pt.Y] {
+					for _, x := range columns[pt.X] {
+						sumCb += int(cb[y*w+x])
+						sumCr += int(cr[y*w+x])
+						n++
+					}
+				}
+				if n == 0 {
+					return
+				}
+
+				ci := pt.Y*cw + pt.X
+				dst.Cb[ci] = uint8((sumCb + n/2) / n)
+				dst.Cr[ci] = uint8((sumCr + n/2) / n)
+			},
+		),
+	)(image.Rect(0, 0, cw, ch))
+}
+
+// subsampleFactors returns the number of columns and rows of pixels that
+// share each chroma sample under the given subsampling ratio.
+func subsampleFactors(ratio image.YCbCrSubsampleRatio) (rx, ry int) {
+	switch ratio {
+	case image.YCbCrSubsampleRatio422:
+		return 2, 1
+	case image.YCbCrSubsampleRatio420:
+		return 2, 2
+	case image.YCbCrSubsampleRatio440:
+		return 1, 2
+	case image.YCbCrSubsampleRatio411:
+		return 4, 1
+	case image.YCbCrSubsampleRatio410:
+		return 4, 2
+	}
+	return 1, 1
+}
+
+// chromaRect returns the bounds of the chroma samples of an image with the
+// given bounds and subsampling ratio, in coordinates where the pixel at
+// (x, y) shares the sample at (x/rx, y/ry), as with COffset.
+func chromaRect(r image.Rectangle, ratio image.YCbCrSubsampleRatio) image.Rectangle {
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+
+	rx, ry := subsampleFactors(ratio)
+	return image.Rect(r.Min.X/rx, r.Min.Y/ry, (r.Max.X-1)/rx+1, (r.Max.Y-1)/ry+1)
+}
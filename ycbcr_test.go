@@ -0,0 +1,85 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestConvertToYCbCr(t *testing.T) {
+	src := randomNRGBA64(image.Rect(-3, 1, 20, 16))
+	bounds := src.Bounds()
+
+	for _, ratio := range []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio422,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440,
+		image.YCbCrSubsampleRatio411,
+		image.YCbCrSubsampleRatio410,
+	} {
+		dst := ConvertToYCbCr(src, ratio)
+		if dst.SubsampleRatio != ratio {
+			t.Fatal("expected ratio", ratio, "found", dst.SubsampleRatio)
+		}
+
+		// Sum the chroma of the pixels sharing each sample.
+		type sum struct{ cb, cr, n int }
+		sums := make(map[int]*sum)
+		AllPointsRP(func(pt image.Point) {
+			c := color.YCbCrModel.Convert(src.At(pt.X, pt.Y)).(color.YCbCr)
+			if y := dst.Y[dst.YOffset(pt.X, pt.Y)]; y != c.Y {
+				t.Fatalf("%v: expected luma %d, found %d at %v", ratio, c.Y, y, pt)
+			}
+
+			ci := dst.COffset(pt.X, pt.Y)
+			if sums[ci] == nil {
+				sums[ci] = new(sum)
+			}
+			sums[ci].cb += int(c.Cb)
+			sums[ci].cr += int(c.Cr)
+			sums[ci].n++
+		})(bounds)
+
+		for ci, s := range sums {
+			cb := uint8((s.cb + s.n/2) / s.n)
+			cr := uint8((s.cr + s.n/2) / s.n)
+			if dst.Cb[ci] != cb || dst.Cr[ci] != cr {
+				t.Fatalf("%v: expected chroma (%d, %d), found (%d, %d) at sample %d", ratio, cb, cr, dst.Cb[ci], dst.Cr[ci], ci)
+			}
+		}
+	}
+}
+
+func TestConvertToNYCbCrA(t *testing.T) {
+	src := randomNRGBA64(image.Rect(0, 0, 16, 16))
+	dst := ConvertToNYCbCrA(src, image.YCbCrSubsampleRatio444)
+
+	AllPointsRP(func(pt image.Point) {
+		expected := color.NYCbCrAModel.Convert(src.At(pt.X, pt.Y))
+		if c := dst.At(pt.X, pt.Y); c != expected {
+			t.Fatalf("expected %v, found %v at %v", expected, c, pt)
+		}
+	})(src.Bounds())
+
+	if ConvertToNYCbCrA(dst, image.YCbCrSubsampleRatio444) != dst {
+		t.Error("converting to the same ratio did not return the source")
+	}
+}
+
+func TestYCbCrSet(t *testing.T) {
+	img := &YCbCr{image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420)}
+	c := color.YCbCr{Y: 10, Cb: 20, Cr: 30}
+
+	img.Set(1, 1, c)
+	if found := img.YCbCrAt(1, 1); found != c {
+		t.Error("expected", c, "found", found)
+	}
+
+	// The pixel sharing the chroma sample has the new chroma.
+	if found := img.YCbCrAt(0, 0); found.Cb != c.Cb || found.Cr != c.Cr {
+		t.Error("expected shared chroma, found", found)
+	}
+
+	img.Set(10, 10, c)
+}